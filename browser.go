@@ -0,0 +1,372 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"jf_requests/jf_requests"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// ANSI escape sequences used to draw the browser.
+const (
+	ansiAltScreenOn  = "\033[?1049h"
+	ansiAltScreenOff = "\033[?1049l"
+	ansiHideCursor   = "\033[?25l"
+	ansiShowCursor   = "\033[?25h"
+	ansiClear        = "\033[H\033[2J"
+	ansiReverse      = "\033[7m"
+	ansiBold         = "\033[1m"
+	ansiReset        = "\033[0m"
+)
+
+type browserKey int
+
+const (
+	keyNone browserKey = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keySpace
+	keyBackspace
+	keyQuit
+	keySelectAll
+	keyDownload
+)
+
+// A single list within the browser, e.g. the libraries or the seasons of a series.
+type browserLevel struct {
+	Parent *jf_requests.Item
+	Items  []jf_requests.Item
+	Cursor int
+	Offset int
+}
+
+// Full screen terminal browser which allows to navigate through the libraries and to queue
+// items for download.
+type Browser struct {
+	ctx     *DownloadContext
+	levels  []*browserLevel
+	queue   []jf_requests.Item
+	message string
+}
+
+func NewBrowser(ctx *DownloadContext) *Browser {
+	return &Browser{ctx: ctx}
+}
+
+// Reads a single key press from the terminal, which has to be in raw mode.
+func readKey() (browserKey, error) {
+	buffer := make([]byte, 8)
+	n, err := os.Stdin.Read(buffer)
+	if err != nil {
+		return keyNone, err
+	}
+
+	input := string(buffer[:n])
+	switch input {
+	case "\033[A", "k":
+		return keyUp, nil
+	case "\033[B", "j":
+		return keyDown, nil
+	case "\033[C", "l":
+		return keyRight, nil
+	case "\033[D", "h":
+		return keyLeft, nil
+	case "\033[5~":
+		return keyPageUp, nil
+	case "\033[6~":
+		return keyPageDown, nil
+	case "\033[H", "\033[1~", "g":
+		return keyHome, nil
+	case "\033[F", "\033[4~", "G":
+		return keyEnd, nil
+	case "\r", "\n":
+		return keyEnter, nil
+	case " ":
+		return keySpace, nil
+	case "\x7f", "\b":
+		return keyBackspace, nil
+	case "q", "\x03", "\033":
+		return keyQuit, nil
+	case "a":
+		return keySelectAll, nil
+	case "d":
+		return keyDownload, nil
+	}
+
+	return keyNone, nil
+}
+
+func (browser *Browser) current() *browserLevel {
+	return browser.levels[len(browser.levels)-1]
+}
+
+// Loads the children of the given item and shows them as new level. A nil parent loads the
+// libraries of the user.
+func (browser *Browser) open(parent *jf_requests.Item) error {
+	var items []jf_requests.Item
+	var err error
+	if parent == nil {
+		items, err = jf_requests.GetRootItems(browser.ctx.Auth, browser.ctx.BaseUrl)
+	} else {
+		items, err = jf_requests.GetChildItemsWithDetails(browser.ctx.Auth, browser.ctx.BaseUrl, parent)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	browser.levels = append(browser.levels, &browserLevel{Parent: parent, Items: items})
+	return nil
+}
+
+func (browser *Browser) isQueued(item *jf_requests.Item) bool {
+	for _, queued := range browser.queue {
+		if queued.Id == item.Id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Adds the item to the download queue or removes it, if it is already queued.
+func (browser *Browser) toggle(item *jf_requests.Item) {
+	for idx, queued := range browser.queue {
+		if queued.Id == item.Id {
+			browser.queue = append(browser.queue[:idx], browser.queue[idx+1:]...)
+			return
+		}
+	}
+
+	if !item.IsFolder && !item.CanDownload {
+		browser.message = fmt.Sprintf("\"%s\" can not be downloaded due to insufficient permission", item.Name)
+		return
+	}
+
+	if _, ok := downloadHandlers[item.Type]; !ok {
+		browser.message = fmt.Sprintf("Items of type \"%s\" can not be downloaded", item.Type)
+		return
+	}
+
+	browser.queue = append(browser.queue, *item)
+}
+
+func (browser *Browser) breadcrumb() string {
+	parts := []string{"Libraries"}
+	for _, level := range browser.levels {
+		if level.Parent != nil {
+			parts = append(parts, level.Parent.Name)
+		}
+	}
+
+	return strings.Join(parts, " › ")
+}
+
+func (browser *Browser) describe(item *jf_requests.Item) string {
+	checkbox := "[ ]"
+	if browser.isQueued(item) {
+		checkbox = "[x]"
+	}
+
+	name := item.Name
+	if item.ProductionYear != 0 && item.Type != "Episode" && item.Type != "Season" {
+		name += fmt.Sprintf(" (%d)", item.ProductionYear)
+	}
+	if item.IsFolder {
+		name += "/"
+	}
+
+	details := item.Type
+	if item.Size > 0 {
+		details += ", " + jf_requests.FormatSize(item.Size)
+	}
+	if !item.IsFolder && !item.CanDownload {
+		details += ", not downloadable"
+	}
+
+	return fmt.Sprintf("%s %s (%s)", checkbox, name, details)
+}
+
+func (browser *Browser) render() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	level := browser.current()
+	visibleRows := height - 5
+	if visibleRows < 1 {
+		visibleRows = 1
+	}
+
+	// Keep the cursor within the visible area.
+	if level.Cursor < level.Offset {
+		level.Offset = level.Cursor
+	} else if level.Cursor >= level.Offset+visibleRows {
+		level.Offset = level.Cursor - visibleRows + 1
+	}
+
+	var screen strings.Builder
+	screen.WriteString(ansiClear)
+	fmt.Fprintf(&screen, "%s%s%s\r\n\r\n", ansiBold, truncate(browser.breadcrumb(), width), ansiReset)
+
+	if len(level.Items) == 0 {
+		screen.WriteString("  (empty)\r\n")
+	}
+
+	for idx := level.Offset; idx < len(level.Items) && idx < level.Offset+visibleRows; idx++ {
+		line := truncate("  "+browser.describe(&level.Items[idx]), width)
+		if idx == level.Cursor {
+			line = ansiReverse + line + ansiReset
+		}
+		screen.WriteString(line + "\r\n")
+	}
+
+	for row := len(level.Items) - level.Offset; row < visibleRows; row++ {
+		screen.WriteString("\r\n")
+	}
+
+	screen.WriteString("\r\n")
+	if browser.message != "" {
+		fmt.Fprintf(&screen, "%s\r\n", truncate(browser.message, width))
+		browser.message = ""
+	} else {
+		fmt.Fprintf(&screen, "%d queued | ↑↓ move  → open  ← back  space select  a all  d download  q quit\r\n", len(browser.queue))
+	}
+
+	fmt.Print(screen.String())
+}
+
+func truncate(text string, width int) string {
+	runes := []rune(text)
+	if width > 1 && len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+
+	return text
+}
+
+// Runs the browser until the user starts the download or quits. Returns the queued items,
+// or nil if the user quit.
+func (browser *Browser) Run() ([]jf_requests.Item, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, errors.New("the browser requires an interactive terminal")
+	}
+
+	if err := browser.open(nil); err != nil {
+		return nil, err
+	}
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Print(ansiAltScreenOn + ansiHideCursor)
+	defer func() {
+		fmt.Print(ansiShowCursor + ansiAltScreenOff)
+		term.Restore(int(os.Stdin.Fd()), state)
+	}()
+
+	for {
+		browser.render()
+
+		key, err := readKey()
+		if err != nil {
+			return nil, err
+		}
+
+		level := browser.current()
+		pageSize := 10
+
+		switch key {
+		case keyUp:
+			if level.Cursor > 0 {
+				level.Cursor--
+			}
+		case keyDown:
+			if level.Cursor < len(level.Items)-1 {
+				level.Cursor++
+			}
+		case keyPageUp:
+			level.Cursor = max(level.Cursor-pageSize, 0)
+		case keyPageDown:
+			level.Cursor = max(min(level.Cursor+pageSize, len(level.Items)-1), 0)
+		case keyHome:
+			level.Cursor = 0
+		case keyEnd:
+			level.Cursor = max(len(level.Items)-1, 0)
+		case keyRight, keyEnter:
+			if len(level.Items) == 0 {
+				continue
+			}
+
+			item := &level.Items[level.Cursor]
+			if !item.IsFolder {
+				if key == keyEnter {
+					browser.toggle(item)
+				}
+				continue
+			}
+
+			browser.message = "Loading..."
+			browser.render()
+			if err := browser.open(item); err != nil {
+				browser.message = fmt.Sprintf("Failed to open \"%s\": %s", item.Name, err)
+			}
+		case keyLeft, keyBackspace:
+			if len(browser.levels) > 1 {
+				browser.levels = browser.levels[:len(browser.levels)-1]
+			}
+		case keySpace:
+			if len(level.Items) > 0 {
+				browser.toggle(&level.Items[level.Cursor])
+				if level.Cursor < len(level.Items)-1 {
+					level.Cursor++
+				}
+			}
+		case keySelectAll:
+			for idx := range level.Items {
+				if !browser.isQueued(&level.Items[idx]) {
+					browser.toggle(&level.Items[idx])
+				}
+			}
+		case keyDownload:
+			if len(browser.queue) == 0 {
+				browser.message = "Select at least one item with space first"
+				continue
+			}
+			return browser.queue, nil
+		case keyQuit:
+			return nil, nil
+		}
+	}
+}
+
+// Lets the user browse the libraries and downloads the selected items.
+func Browse(args *Arguments, auth *jf_requests.AuthResponse) bool {
+	ctx := NewDownloadContext(args, auth)
+
+	queue, err := NewBrowser(ctx).Run()
+	if err != nil {
+		color.Red("Failed to run the browser: %s", err)
+		return false
+	}
+
+	if queue == nil {
+		return true
+	}
+
+	return downloadItems(ctx, &jf_requests.Item{Name: "Selected Items"}, queue)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"jf_requests/jf_requests"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/fatih/color"
+)
+
+// A command which can be passed as first argument.
+type Command struct {
+	Description string
+	Run         func(args *Arguments, auth *jf_requests.AuthResponse) bool
+	// Set, if the command works without the server. It is run without authentication.
+	Offline bool
+}
+
+var commands map[string]Command
+
+func init() {
+	commands = map[string]Command{
+		"browse": {
+			Description: "Browse the libraries in a full screen terminal UI and select the items to download",
+			Run:         Browse,
+		},
+		"download": {
+			Description: "Download the series, movie or other item given by -seriesid, -name or -playlist (default)",
+			Run:         Download,
+		},
+		"list": {
+			Description: "List the content of all libraries (or of -library) with size, codec and download permission. Use -export to write CSV or JSON",
+			Run:         ListLibraries,
+		},
+		"mirror": {
+			Description: "Download all series and movies of all libraries (or of -library) into -output, skipping files which already exist",
+			Run:         Mirror,
+		},
+		"nextup": {
+			Description: "Download the next episode of every series you are watching. Use -next-up to get more episodes per series",
+			Run:         DownloadNextUp,
+		},
+		"resume": {
+			Description: "Download all episodes and movies you started but did not finish",
+			Run:         DownloadResume,
+		},
+		"sync-watched": {
+			Description: "Mark the items listed in the -watched file as watched on the server",
+			Run:         SyncWatched,
+		},
+		"verify": {
+			Description: "Check the files downloaded with -verify in -output against their recorded checksums",
+			Run:         VerifyDownloads,
+			Offline:     true,
+		},
+	}
+}
+
+func PrintUsage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s\n    \t%s\n", name, commands[name].Description)
+	}
+
+	fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func DownloadNextUp(args *Arguments, auth *jf_requests.AuthResponse) bool {
+	ctx := NewDownloadContext(args, auth)
+
+	items, err := jf_requests.GetNextUpItems(auth, args.BaseUrl)
+	if err != nil {
+		color.Red("Failed to obtain the next up episodes: %s", err)
+		return false
+	}
+
+	if args.NextUp <= 0 {
+		return downloadItems(ctx, &jf_requests.Item{Name: "Next Up"}, items)
+	}
+
+	return downloadNextEpisodes(ctx, items, args.NextUp)
+}
+
+// Downloads the next episodes of every series of the given next up episodes.
+func downloadNextEpisodes(ctx *DownloadContext, nextUp []jf_requests.Item, count int) bool {
+	var series []jf_requests.Item
+	for _, episode := range nextUp {
+		seriesItem, err := jf_requests.GetItemForId(ctx.Auth, ctx.BaseUrl, episode.SeriesId)
+		if err != nil {
+			color.Red("Failed to obtain series of episode \"%s\": %s", episode.Name, err)
+			continue
+		}
+
+		series = append(series, *seriesItem)
+	}
+
+	if len(series) == 0 {
+		color.Yellow("There are no series to continue.")
+		return false
+	}
+
+	fmt.Printf("The next %d episodes of the following series will be downloaded:\n", count)
+	for idx, item := range series {
+		color.Cyan("  %d. %s", idx+1, item.Name)
+	}
+
+	if !GetConfirmation() {
+		return false
+	}
+
+	seriesCtx := *ctx
+	seriesCtx.Confirmed = true
+
+	success := true
+	for idx := range series {
+		if !DownloadSeries(&seriesCtx, &series[idx]) {
+			success = false
+		}
+	}
+
+	return success
+}
+
+func DownloadResume(args *Arguments, auth *jf_requests.AuthResponse) bool {
+	ctx := NewDownloadContext(args, auth)
+
+	items, err := jf_requests.GetResumeItems(auth, args.BaseUrl)
+	if err != nil {
+		color.Red("Failed to obtain the items to continue watching: %s", err)
+		return false
+	}
+
+	return downloadItems(ctx, &jf_requests.Item{Name: "Continue Watching"}, items)
+}
+
+// Marks all items of the watched list as played on the server. Items which are already
+// marked as watched on the server or which can not be found are reported as conflicts.
+func SyncWatched(args *Arguments, auth *jf_requests.AuthResponse) bool {
+	entries, err := jf_requests.ReadWatchedList(args.WatchedFile)
+	if err != nil {
+		color.Red(err.Error())
+		return false
+	}
+
+	if args.DryRun {
+		color.Yellow("Dry run: nothing will be changed on the server.")
+	}
+
+	var marked, alreadyWatched, failed int
+	for _, entry := range entries {
+		item, userData, err := jf_requests.GetItemWithUserData(auth, args.BaseUrl, entry.Id)
+		if err != nil {
+			color.Red("Conflict: %s", err)
+			failed++
+			continue
+		}
+
+		name := item.Name
+		if item.SeriesName != "" {
+			name = fmt.Sprintf("%s - %s", item.SeriesName, item.Name)
+		}
+
+		if userData.Played {
+			color.Yellow("Conflict: \"%s\" is already marked as watched on the server", name)
+			alreadyWatched++
+			continue
+		}
+
+		if args.DryRun {
+			color.Green("Would mark \"%s\" as watched", name)
+			marked++
+			continue
+		}
+
+		if err := jf_requests.MarkAsPlayed(auth, args.BaseUrl, entry); err != nil {
+			color.Red("Failed to mark \"%s\" as watched: %s", name, err)
+			failed++
+			continue
+		}
+
+		color.Green("Marked \"%s\" as watched", name)
+		marked++
+	}
+
+	fmt.Printf("%d marked as watched, %d already watched, %d failed\n", marked, alreadyWatched, failed)
+	return failed == 0
+}
+
+// Checks all files of the checksum manifest in the output directory for modifications and
+// damaged media containers.
+func VerifyDownloads(args *Arguments, auth *jf_requests.AuthResponse) bool {
+	manifest, err := jf_requests.ReadChecksumManifest(args.Output)
+	if err != nil {
+		color.Red(err.Error())
+		return false
+	}
+
+	files := manifest.GetFiles()
+	if len(files) == 0 {
+		color.Yellow("No checksums found in \"%s\". Download with -verify to record them.", manifest.Directory)
+		return false
+	}
+
+	var ok, failed, missing int
+	for _, file := range files {
+		path := filepath.Join(manifest.Directory, file)
+		if _, err := os.Stat(path); err != nil {
+			color.Red("MISSING %s", file)
+			missing++
+			continue
+		}
+
+		if err := jf_requests.ProbeContainer(path); err != nil {
+			color.Red("FAILED  %s: %s", file, err)
+			failed++
+			continue
+		}
+
+		checksum, err := jf_requests.GetSha256(path)
+		if err != nil {
+			color.Red("FAILED  %s: %s", file, err)
+			failed++
+			continue
+		} else if checksum != manifest.Checksums[file] {
+			color.Red("FAILED  %s: checksum mismatch", file)
+			failed++
+			continue
+		}
+
+		color.Green("OK      %s", file)
+		ok++
+	}
+
+	fmt.Printf("%d ok, %d failed, %d missing\n", ok, failed, missing)
+	return failed == 0 && missing == 0
+}
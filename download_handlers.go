@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"jf_requests/jf_requests"
+
+	"github.com/fatih/color"
+)
+
+// Everything a download handler needs to know about the current run.
+type DownloadContext struct {
+	Auth     *jf_requests.AuthResponse
+	BaseUrl  string
+	SeasonId string
+	Options  *jf_requests.DownloadOptions
+	// Restricts the episodes of a series which are downloaded.
+	EpisodeFilter *jf_requests.EpisodeFilter
+	// Set, if the user already confirmed the download, e.g. for all items of a collection.
+	// Handlers must not ask for another confirmation or selection in this case.
+	Confirmed bool
+}
+
+// Downloads the given item. Returns false, if the download failed or was aborted.
+type DownloadHandler func(ctx *DownloadContext, item *jf_requests.Item) bool
+
+// Handlers for all supported item types, keyed by Item.Type.
+var downloadHandlers map[string]DownloadHandler
+
+func init() {
+	downloadHandlers = map[string]DownloadHandler{
+		"Series":           DownloadSeries,
+		"Season":           DownloadSeason,
+		"Episode":          DownloadEpisode,
+		"Movie":            DownloadMovie,
+		"Video":            DownloadFile,
+		"MusicVideo":       DownloadFile,
+		"BoxSet":           DownloadCollection,
+		"Playlist":         DownloadPlaylist,
+		"Folder":           DownloadChildren,
+		"CollectionFolder": DownloadChildren,
+		"MusicAlbum":       DownloadAlbum,
+		"MusicArtist":      DownloadArtist,
+		"Audio":            DownloadFile,
+		"AudioBook":        DownloadFile,
+		"Book":             DownloadFile,
+	}
+}
+
+// Downloads the given item with the handler registered for its type.
+func DownloadItem(ctx *DownloadContext, item *jf_requests.Item) bool {
+	handler, ok := downloadHandlers[item.Type]
+	if !ok {
+		color.Yellow("Items of type \"%s\" can not be downloaded: %s", item.Type, item.Name)
+		return false
+	}
+
+	return handler(ctx, item)
+}
+
+// Downloads a single season by resolving the series it belongs to.
+func DownloadSeason(ctx *DownloadContext, item *jf_requests.Item) bool {
+	if item.SeriesId == "" {
+		color.Red("Could not find the series of season \"%s\"", item.Name)
+		return false
+	}
+
+	series, err := jf_requests.GetItemForId(ctx.Auth, ctx.BaseUrl, item.SeriesId)
+	if err != nil {
+		color.Red("Failed to obtain series of season \"%s\": %s", item.Name, err)
+		return false
+	}
+
+	seasonCtx := *ctx
+	seasonCtx.SeasonId = item.Id
+	return DownloadSeries(&seasonCtx, series)
+}
+
+// Downloads a single episode. The series and season it belongs to are resolved, so that
+// the episode is named the same way as when the whole season is downloaded.
+func DownloadEpisode(ctx *DownloadContext, item *jf_requests.Item) bool {
+	if item.SeriesId == "" {
+		color.Red("Could not find the series of episode \"%s\"", item.Name)
+		return false
+	}
+
+	seriesItem, err := jf_requests.GetItemForId(ctx.Auth, ctx.BaseUrl, item.SeriesId)
+	if err != nil {
+		color.Red("Failed to obtain series of episode \"%s\": %s", item.Name, err)
+		return false
+	}
+
+	series, err := jf_requests.GetSeriesFromItem(ctx.Auth, ctx.BaseUrl, seriesItem, ctx.Options.NeedsMetadata())
+	if err != nil {
+		color.Red("Failed to obtain Episode Information for given id: %s", err)
+		return false
+	}
+
+	season, idx, err := series.GetEpisodeForId(item.Id)
+	if err != nil {
+		color.Red(err.Error())
+		return false
+	}
+
+	episode := season.Episodes[idx]
+	if !episode.CanDownload {
+		color.Yellow("Cannot download \"%s\" due to insufficient permission!", episode.Name)
+		return false
+	}
+
+	if !ctx.Confirmed {
+		fmt.Println("The following Episode will be downloaded:")
+		color.Green(series.Name)
+		color.Cyan("  └ %s", season.Name)
+		color.Cyan("    └ %d. %s", episode.Number, episode.Name)
+		if !GetConfirmation() {
+			return false
+		}
+	}
+
+	if err := season.DownloadEpisode(ctx.BaseUrl, ctx.Auth.Token, ctx.Options, idx); err != nil {
+		color.Red("Failed to download \"%s\": %s", episode.Name, err)
+		return false
+	}
+
+	return true
+}
+
+// Downloads an item which consists of a single file, e.g. an audio track or a book.
+func DownloadFile(ctx *DownloadContext, item *jf_requests.Item) bool {
+	file, err := jf_requests.GetMediaFileFromItem(ctx.Auth, ctx.BaseUrl, item)
+	if err != nil {
+		color.Red("Failed to obtain file for \"%s\": %s", item.Name, err)
+		return false
+	}
+
+	if !file.CanDownload {
+		color.Yellow("Cannot download \"%s\" due to insufficient permission!", file.Name)
+		return false
+	}
+
+	if !ctx.Confirmed {
+		fmt.Printf("The following %s will be downloaded:\n", item.Type)
+		color.Green("Name: %s", file.Name)
+		if !GetConfirmation() {
+			return false
+		}
+	}
+
+	if _, err := file.Download(ctx.BaseUrl, ctx.Auth.Token, ctx.Options, jf_requests.SanitizeFilename(file.Name)); err != nil {
+		color.Red("Failed to download \"%s\": %s", file.Name, err)
+		return false
+	}
+
+	return true
+}
+
+// Confirms and downloads the given list of items as a unit.
+func downloadItems(ctx *DownloadContext, parent *jf_requests.Item, children []jf_requests.Item) bool {
+	if len(children) == 0 {
+		color.Yellow("\"%s\" does not contain any items.", parent.Name)
+		return false
+	}
+
+	if !ctx.Confirmed {
+		fmt.Println("The following Items will be downloaded:")
+		color.Green(parent.Name)
+		for idx, child := range children {
+			name := child.Name
+			if child.SeriesName != "" {
+				name = fmt.Sprintf("%s - %s", child.SeriesName, child.Name)
+			}
+			color.Cyan("  └ %d. %s (%s)", idx+1, name, child.Type)
+		}
+
+		if !GetConfirmation() {
+			return false
+		}
+	}
+
+	childCtx := *ctx
+	childCtx.Confirmed = true
+
+	success := true
+	for idx := range children {
+		// Stop early instead of failing every remaining item, if the disk is full.
+		if err := jf_requests.CheckMinFreeSpace(ctx.Options.OutputDir); err != nil {
+			color.Red(err.Error())
+			return false
+		}
+
+		if !DownloadItem(&childCtx, &children[idx]) {
+			success = false
+		}
+	}
+
+	return success
+}
+
+// Downloads all children of a container item like a collection, playlist or folder.
+func DownloadChildren(ctx *DownloadContext, item *jf_requests.Item) bool {
+	children, err := jf_requests.GetItemsForParentId(ctx.Auth, ctx.BaseUrl, item)
+	if err != nil {
+		color.Red("Failed to obtain the items of \"%s\": %s", item.Name, err)
+		return false
+	}
+
+	return downloadItems(ctx, item, children)
+}
+
+// Downloads all tracks of a music album into an "Artist/Album" directory.
+func DownloadAlbum(ctx *DownloadContext, item *jf_requests.Item) bool {
+	album, err := jf_requests.GetAlbumFromItem(ctx.Auth, ctx.BaseUrl, item)
+	if err != nil {
+		color.Red("Failed to obtain the tracks of \"%s\": %s", item.Name, err)
+		return false
+	}
+
+	if len(album.Tracks) == 0 {
+		color.Yellow("\"%s\" does not contain any tracks.", album.Name)
+		return false
+	}
+
+	if !ctx.Confirmed && !album.PrintAndGetConfirmation() {
+		return false
+	}
+
+	if err := album.Download(ctx.BaseUrl, ctx.Auth.Token, ctx.Options); err != nil {
+		color.Red("Failed to download \"%s\": %s", album.Name, err)
+		return false
+	}
+
+	return true
+}
+
+// Downloads all movies and series of a collection into a directory named after it.
+func DownloadCollection(ctx *DownloadContext, item *jf_requests.Item) bool {
+	collection, err := jf_requests.GetCollectionFromItem(ctx.Auth, ctx.BaseUrl, item)
+	if err != nil {
+		color.Red("Failed to obtain the items of \"%s\": %s", item.Name, err)
+		return false
+	}
+
+	if len(collection.Items) == 0 {
+		color.Yellow("\"%s\" does not contain any items.", collection.Name)
+		return false
+	}
+
+	if !ctx.Confirmed && !collection.PrintAndGetConfirmation() {
+		return false
+	}
+
+	options := *ctx.Options
+	options.OutputDir = options.GetOutputPath(collection.GetDirectory())
+
+	// The collection was confirmed already, so downloadItems must not ask again.
+	collectionCtx := *ctx
+	collectionCtx.Options = &options
+	collectionCtx.Confirmed = true
+
+	return downloadItems(&collectionCtx, item, collection.Items)
+}
+
+// Downloads all entries of a playlist in their order and writes an m3u8 file for them.
+func DownloadPlaylist(ctx *DownloadContext, item *jf_requests.Item) bool {
+	playlist, err := jf_requests.GetPlaylistFromItem(ctx.Auth, ctx.BaseUrl, item)
+	if err != nil {
+		color.Red(err.Error())
+		return false
+	}
+
+	if len(playlist.Entries) == 0 {
+		color.Yellow("\"%s\" does not contain any items.", playlist.Name)
+		return false
+	}
+
+	if !ctx.Confirmed && !playlist.PrintAndGetConfirmation() {
+		return false
+	}
+
+	if err := playlist.Download(ctx.BaseUrl, ctx.Auth.Token, ctx.Options); err != nil {
+		color.Red(err.Error())
+		return false
+	}
+
+	return true
+}
+
+// Downloads all albums of a music artist.
+func DownloadArtist(ctx *DownloadContext, item *jf_requests.Item) bool {
+	albums, err := jf_requests.GetAlbumsForArtistId(ctx.Auth, ctx.BaseUrl, item.Id)
+	if err != nil {
+		color.Red("Failed to obtain the albums of \"%s\": %s", item.Name, err)
+		return false
+	}
+
+	return downloadItems(ctx, item, albums)
+}
@@ -8,6 +8,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/fatih/color"
 )
 
 func GetConfirmation() bool {
@@ -36,3 +38,157 @@ func GetUserChoice(number_of_choices int) (int, error) {
 		return -1, errors.New("only provide a single number")
 	}
 }
+
+// Parses a selection of choices like "1,3,5-7". "all" (or 0) selects every choice, entries
+// starting with ! are excluded, e.g. "!2" selects everything but the second choice. Returns
+// the selected choices in ascending order, starting at 1.
+func ParseChoices(input string, number_of_choices int) ([]int, error) {
+	selected := make([]bool, number_of_choices+1)
+	tokens := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+
+	if len(tokens) == 0 {
+		return nil, errors.New("no selection given")
+	}
+
+	// If there are only exclusions, they are applied to all choices.
+	onlyExclusions := true
+	for _, token := range tokens {
+		if !strings.HasPrefix(token, "!") {
+			onlyExclusions = false
+		}
+	}
+	if onlyExclusions {
+		tokens = append([]string{"all"}, tokens...)
+	}
+
+	for _, token := range tokens {
+		exclude := strings.HasPrefix(token, "!")
+		token = strings.TrimPrefix(token, "!")
+
+		var from, to int
+		if token == "all" || token == "0" {
+			from, to = 1, number_of_choices
+		} else if start, end, isRange := strings.Cut(token, "-"); isRange {
+			var errFrom, errTo error
+			from, errFrom = strconv.Atoi(start)
+			to, errTo = strconv.Atoi(end)
+			if errFrom != nil || errTo != nil || from > to {
+				return nil, errors.New(fmt.Sprintf("invalid range: %s", token))
+			}
+		} else {
+			number, err := strconv.Atoi(token)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid selection: %s", token))
+			}
+			from, to = number, number
+		}
+
+		if from < 1 || to > number_of_choices {
+			return nil, errors.New(fmt.Sprintf("selection out of range: %s", token))
+		}
+
+		for choice := from; choice <= to; choice++ {
+			selected[choice] = !exclude
+		}
+	}
+
+	var choices []int
+	for choice := 1; choice <= number_of_choices; choice++ {
+		if selected[choice] {
+			choices = append(choices, choice)
+		}
+	}
+
+	if len(choices) == 0 {
+		return nil, errors.New("nothing selected")
+	}
+
+	return choices, nil
+}
+
+// Asks the user to select one or more of the given number of choices. See ParseChoices for
+// the supported format. If emptyMeansAll is set, just pressing enter selects all choices.
+func GetUserChoices(number_of_choices int, emptyMeansAll bool) ([]int, error) {
+	fmt.Print("==> ")
+	reader := bufio.NewReader(os.Stdin)
+	response, _ := reader.ReadString('\n')
+	response = strings.TrimSpace(response)
+
+	if response == "" && emptyMeansAll {
+		response = "all"
+	}
+
+	return ParseChoices(response, number_of_choices)
+}
+
+// Parses a human readable size like "500K", "5M" or "10G" into bytes. The suffixes are
+// interpreted as powers of 1024. A plain number is treated as bytes.
+func ParseSize(size string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(size))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+
+	multiplier := int64(1)
+	if len(value) > 0 {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, errors.New(fmt.Sprintf("invalid size: %s", size))
+	}
+
+	return int64(number * float64(multiplier)), nil
+}
+
+// Formats the given number of bytes as human readable size, e.g. "4.2 GiB".
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// Prints the entries of a download confirmation. Entries which can not be downloaded from the
+// Jellyfin server due to the CanDownload attribute set to false are struck through.
+type confirmationList struct {
+	undownloadableItemsPresent bool
+}
+
+func (list *confirmationList) PrintEntry(line string, canDownload bool) {
+	if !canDownload {
+		line = fmt.Sprintf("\033[9m%s\033[0m", line)
+		list.undownloadableItemsPresent = true
+	}
+	color.Cyan(line)
+}
+
+// Explains the struck through entries, if there were any.
+func (list *confirmationList) PrintWarning() {
+	if list.undownloadableItemsPresent {
+		color.Yellow("Some items cannot be downloaded due to insufficient permission!")
+		color.Yellow("The affected Items are struck through.")
+	}
+}
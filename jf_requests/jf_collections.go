@@ -0,0 +1,54 @@
+package jf_requests
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+)
+
+// A BoxSet which groups multiple movies or series, e.g. all movies of a franchise.
+type Collection struct {
+	Name  string
+	Id    string
+	Items []Item
+}
+
+func GetCollectionFromItem(auth *AuthResponse, baseurl string, item *Item) (*Collection, error) {
+	requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items?ParentId=%s&Fields=CanDownload&SortBy=ProductionYear,SortName", auth.UserId, item.Id)
+
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Collection{
+		Name:  item.Name,
+		Id:    item.Id,
+		Items: GetItem(res["Items"].([]any), item),
+	}, nil
+}
+
+// Returns the name of the directory in which the items of the collection are stored.
+func (collection *Collection) GetDirectory() string {
+	return SanitizeFilename(collection.Name)
+}
+
+func (collection *Collection) PrintAndGetConfirmation() bool {
+	fmt.Println("The following Items will be downloaded:")
+	color.Green(collection.Name)
+	var list confirmationList
+
+	for idx, item := range collection.Items {
+		outstring := fmt.Sprintf("  └ %d. %s", idx+1, item.Name)
+		if item.ProductionYear != 0 {
+			outstring += fmt.Sprintf(" (%d)", item.ProductionYear)
+		}
+
+		// Only single files carry a download permission. Series are checked per episode.
+		list.PrintEntry(outstring, item.Type == "Series" || item.CanDownload)
+	}
+
+	list.PrintWarning()
+
+	return GetConfirmation()
+}
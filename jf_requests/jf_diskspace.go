@@ -0,0 +1,148 @@
+package jf_requests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fatih/color"
+)
+
+// If less space than this remains after a download, a warning is shown.
+const lowSpaceMargin int64 = 1 << 30
+
+// Number of bytes after which the free space is checked again while writing a file.
+const spaceCheckInterval int64 = 64 << 20
+
+// Returned if a download was aborted, since the disk is (almost) full. The partial file is
+// kept, so the download can be resumed once space was freed.
+var ErrNotEnoughSpace = errors.New("not enough free disk space")
+
+// Space which has to remain free on the target disk. Shared by all downloads.
+var minFreeSpace int64 = 0
+
+// Sets the space which has to remain free on the target disk. Downloads are aborted once
+// less space is available. 0 only aborts downloads if the disk is actually full.
+func SetMinFreeSpace(bytes int64) {
+	minFreeSpace = bytes
+}
+
+// Returns the number of bytes which are available to the user on the filesystem of the given
+// directory. If the directory does not exist yet, its closest existing parent is used.
+func GetFreeSpace(directory string) (int64, error) {
+	if directory == "" {
+		directory = "."
+	}
+
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		if _, err := os.Stat(directory); err == nil {
+			break
+		}
+
+		parent := filepath.Dir(directory)
+		if parent == directory {
+			break
+		}
+		directory = parent
+	}
+
+	return getFreeSpace(directory)
+}
+
+// Returns the size of the version of an item which will be downloaded. Returns 0, if the
+// size is not known in advance, e.g. because the item is transcoded.
+func (options *DownloadOptions) GetDownloadSize(sources []MediaSource) int64 {
+	if options.Transcode != nil {
+		return 0
+	}
+
+	source, _ := SelectMediaSource(sources, options.VersionFilter)
+	if source == nil {
+		return 0
+	}
+
+	return source.Size
+}
+
+// Prints the size of a download and compares it against the free space in the output
+// directory. Returns false, if the download does not fit. A warning is shown, if only little
+// space remains afterwards.
+func (options *DownloadOptions) CheckFreeSpace(required int64) bool {
+	if options.Transcode != nil {
+		color.Yellow("The size of transcoded files is not known in advance.")
+		return true
+	}
+
+	free, err := GetFreeSpace(options.OutputDir)
+	if err != nil {
+		slog.Debug("Failed to determine free space", "dir", options.OutputDir, "err", err)
+		fmt.Printf("Total size: %s\n", FormatSize(required))
+		return true
+	}
+
+	fmt.Printf("Total size: %s, free space: %s\n", FormatSize(required), FormatSize(free))
+
+	// The reserved space can not be used for the download.
+	available := free - minFreeSpace
+	if required > available {
+		color.Red("Not enough free space for the download: %s are missing.", FormatSize(required-available))
+		return false
+	} else if available-required < lowSpaceMargin {
+		color.Yellow("Only %s of usable space will be left on the target disk after the download.", FormatSize(available-required))
+	}
+
+	return true
+}
+
+// Returns an error wrapping ErrNotEnoughSpace, if less than the reserved space is free in
+// the given directory or if the disk is full, so that callers can stop early.
+func CheckMinFreeSpace(directory string) error {
+	free, err := GetFreeSpace(directory)
+	if err != nil {
+		slog.Debug("Failed to determine free space", "dir", directory, "err", err)
+		return nil
+	}
+
+	if free <= 0 {
+		return fmt.Errorf("%w: the disk is full", ErrNotEnoughSpace)
+	} else if free < minFreeSpace {
+		return fmt.Errorf("%w: only %s left, but %s have to remain free", ErrNotEnoughSpace, FormatSize(free), FormatSize(minFreeSpace))
+	}
+
+	return nil
+}
+
+// Writer which regularly checks the free space of the disk it writes to and fails before
+// the reserved space is used up.
+type spaceGuardWriter struct {
+	writer    io.Writer
+	directory string
+	unchecked int64
+}
+
+func (guard *spaceGuardWriter) Write(data []byte) (int, error) {
+	if guard.unchecked >= spaceCheckInterval {
+		guard.unchecked = 0
+		if err := CheckMinFreeSpace(guard.directory); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := guard.writer.Write(data)
+	guard.unchecked += int64(n)
+
+	if errors.Is(err, syscall.ENOSPC) {
+		err = fmt.Errorf("%w: %s", ErrNotEnoughSpace, err)
+	}
+
+	return n, err
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package jf_requests
+
+import "golang.org/x/sys/unix"
+
+func getFreeSpace(directory string) (int64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(directory, &stat); err != nil {
+		return 0, err
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
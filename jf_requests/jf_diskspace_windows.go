@@ -0,0 +1,19 @@
+//go:build windows
+
+package jf_requests
+
+import "golang.org/x/sys/windows"
+
+func getFreeSpace(directory string) (int64, error) {
+	path, err := windows.UTF16PtrFromString(directory)
+	if err != nil {
+		return 0, err
+	}
+
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, &total, &free); err != nil {
+		return 0, err
+	}
+
+	return int64(available), nil
+}
@@ -4,14 +4,122 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/fatih/color"
 	"github.com/schollz/progressbar/v3"
 )
 
+// Options which control how items are downloaded and how the resulting files are named.
+type DownloadOptions struct {
+	KeepFilenames bool
+	// If set, the items are transcoded by the server instead of downloading the original file.
+	Transcode *TranscodeOptions
+	// Languages of the subtitles which are downloaded next to the media, e.g. "eng".
+	SubtitleLanguages []string
+	// Selects the version to download for items with multiple media sources,
+	// e.g. "1080p", "smallest" or "largest".
+	VersionFilter string
+	// If set, Kodi compatible nfo files are written next to the downloaded media.
+	WriteNfo bool
+	// If set, posters, backdrops, logos and thumbnails are downloaded next to the media.
+	Images bool
+	// Directory in which all files are stored. Defaults to the working directory.
+	OutputDir string
+	// If set, media files which already exist are not downloaded again.
+	SkipExisting bool
+	// Optional statistics which are updated for every downloaded media file.
+	Stats *DownloadStats
+	// If set, the size and structure of downloaded media files are verified.
+	Verify bool
+	// Optional manifest in which the checksums of verified files are recorded.
+	Checksums *ChecksumManifest
+	// Optional report which lists every media file attempted to download.
+	Report *DownloadReport
+	// Set if the output directory already is the directory of the series, e.g. when
+	// mirroring, so that series downloads do not create another one.
+	InSeriesDirectory bool
+	// Directory in which the files may already exist from a run with the other series
+	// layout, see Series.Download. Only used with SkipExisting.
+	alternativeOutputDir string
+}
+
+// Returns whether the metadata of the items is needed, which is only the case for nfo files
+// and artwork.
+func (options *DownloadOptions) NeedsMetadata() bool {
+	return options.WriteNfo || options.Images
+}
+
+// Returned by downloads of multiple files like albums or seasons, if some of the files failed.
+// The failures themselves were already reported.
+var ErrDownloadsFailed = errors.New("some files failed to download")
+
+func downloadsFailedError(failed int, total int) error {
+	return fmt.Errorf("%w (%d of %d)", ErrDownloadsFailed, failed, total)
+}
+
+// Totals of all media files handled during a run.
+type DownloadStats struct {
+	Downloaded int
+	Skipped    int
+	Failed     int
+	Bytes      int64
+}
+
+func (stats *DownloadStats) String() string {
+	return fmt.Sprintf("%d downloaded (%s), %d skipped, %d failed", stats.Downloaded, FormatSize(stats.Bytes), stats.Skipped, stats.Failed)
+}
+
+// Returns the link which should be used to download the item with the given id. If a media
+// source is given, that specific version of the item is downloaded.
+func (options *DownloadOptions) GetLinkForId(baseUrl string, token string, id string, source *MediaSource) string {
+	mediaSourceId := ""
+	if source != nil {
+		mediaSourceId = source.Id
+	}
+
+	if options.Transcode != nil {
+		return options.Transcode.GetStreamLinkForId(baseUrl, token, id, mediaSourceId)
+	}
+
+	// Alternate versions are stored as separate items, whose id equals the media source id.
+	if mediaSourceId != "" {
+		return GetDownloadLinkForId(baseUrl, token, mediaSourceId)
+	}
+
+	return GetDownloadLinkForId(baseUrl, token, id)
+}
+
+// Returns the name of the file in which an item should be stored. If filenames should not
+// be kept, the given name is used instead of the original filename. The suffix is adapted to
+// the target container, if the item gets transcoded. If name contains a directory, the file
+// is always placed into it.
+func (options *DownloadOptions) GetOutputFilename(filename string, name string) string {
+	suffix := GetSuffixFromFilename(filename)
+	if options.Transcode != nil {
+		suffix = options.Transcode.Container
+	}
+
+	if options.KeepFilenames {
+		kept := fmt.Sprintf("%s.%s", strings.TrimSuffix(filename, "."+GetSuffixFromFilename(filename)), suffix)
+		return filepath.Join(options.OutputDir, filepath.Dir(name), kept)
+	}
+
+	return filepath.Join(options.OutputDir, fmt.Sprintf("%s.%s", name, suffix))
+}
+
+// Returns the path of a file which is stored in the output directory.
+func (options *DownloadOptions) GetOutputPath(name string) string {
+	return filepath.Join(options.OutputDir, name)
+}
+
 func CreatePBar(length int64, description string) *progressbar.ProgressBar {
 	desc := ""
 	return progressbar.NewOptions64(
@@ -32,33 +140,312 @@ func CreatePBar(length int64, description string) *progressbar.ProgressBar {
 	)
 }
 
+// Downloads the file behind the given link into outfile. The data is first written into a
+// ".part" file which is renamed once the download is complete. If the download fails due to
+// a transient error, it is retried according to the retry policy and resumed from the
+// already downloaded offset.
 func DownloadFromUrl(downloadLink string, name string, outfile string, max int, current int) error {
-	req, _ := http.NewRequest("GET", downloadLink, nil)
-	resp, err := http.DefaultClient.Do(req)
+	partfile := outfile + ".part"
+
+	if dir := filepath.Dir(outfile); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.New(fmt.Sprintf("Failed to create directory: %s", err))
+		}
+	}
+
+	if err := CheckMinFreeSpace(filepath.Dir(outfile)); err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		retryable, retryAfter, err := downloadPart(downloadLink, partfile, max, current)
+		if err == nil {
+			break
+		}
+
+		if !retryable || attempt >= retryPolicy.MaxAttempts {
+			return err
+		}
+
+		delay := retryPolicy.Delay(attempt, retryAfter)
+		color.Yellow("Download of \"%s\" failed: %s. Retrying in %s...", name, err, delay.Round(time.Second))
+		time.Sleep(delay)
+	}
+
+	if err := os.Rename(partfile, outfile); err != nil {
+		return errors.New(fmt.Sprintf("Failed to move downloaded file into place: %s", err))
+	}
+
+	return nil
+}
+
+// Downloads a media file like an episode or a movie. Existing files are skipped, if
+// requested, and the statistics and the report are updated with the result. The entry
+// identifies the item within the report. The expected size is used to verify the download
+// and may be 0, if it is not known. Returns whether the file was skipped, so that the
+// caller can skip its subtitles, artwork and nfo files as well.
+func (options *DownloadOptions) downloadMedia(downloadLink string, entry ReportEntry, outfile string, expectedSize int64, max int, current int) (bool, error) {
+	entry.Path = outfile
+
+	if options.SkipExisting {
+		if existing, info := options.findExisting(outfile); info != nil {
+			slog.Debug("Skipping existing file", "file", existing)
+			if options.Stats != nil {
+				options.Stats.Skipped++
+			}
+
+			entry.Path = existing
+			entry.Status = StatusSkipped
+			entry.Bytes = info.Size()
+			entry.Sha256 = options.Checksums.Get(existing)
+			options.addToReport(entry)
+			return true, nil
+		}
+	}
+
+	// Only the bytes transferred during this run count for the speed of resumed downloads.
+	var resumedAt int64
+	if info, err := os.Stat(outfile + ".part"); err == nil {
+		resumedAt = info.Size()
+	}
+
+	started := time.Now()
+	err := DownloadFromUrl(downloadLink, entry.Name, outfile, max, current)
+	duration := time.Since(started)
+
+	if err == nil && options.Verify {
+		err = options.verifyDownload(outfile, expectedSize)
+	}
+
+	// Hashing reads the whole file again, so checksums are only calculated with -verify.
+	if err == nil && options.Verify {
+		entry.Sha256, err = options.recordChecksum(outfile)
+	}
+
+	entry.Status = StatusDownloaded
+	if err != nil {
+		entry.Status = StatusFailed
+		entry.Error = err.Error()
+	}
+
+	if info, statErr := os.Stat(outfile); err == nil && statErr == nil {
+		entry.Bytes = info.Size()
+	} else if info, statErr := os.Stat(outfile + ".part"); statErr == nil {
+		entry.Bytes = info.Size()
+	}
+
+	entry.DurationSeconds = duration.Seconds()
+	if transferred := entry.Bytes - resumedAt; transferred > 0 && duration > 0 {
+		entry.BytesPerSecond = int64(float64(transferred) / duration.Seconds())
+	}
 
+	if options.Stats != nil {
+		if err != nil {
+			options.Stats.Failed++
+		} else {
+			options.Stats.Downloaded++
+			options.Stats.Bytes += entry.Bytes
+		}
+	}
+
+	options.addToReport(entry)
+	return false, err
+}
+
+// Returns the path and the info of outfile, if it already exists. Otherwise the same file in
+// the alternative output directory is checked. Returns nil, if neither exists.
+func (options *DownloadOptions) findExisting(outfile string) (string, os.FileInfo) {
+	candidates := []string{outfile}
+	if options.alternativeOutputDir != "" {
+		outputDir := options.OutputDir
+		if outputDir == "" {
+			outputDir = "."
+		}
+		if relative, err := filepath.Rel(outputDir, outfile); err == nil {
+			candidates = append(candidates, filepath.Join(options.alternativeOutputDir, relative))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, info
+		}
+	}
+
+	return "", nil
+}
+
+func (options *DownloadOptions) addToReport(entry ReportEntry) {
+	if options.Report == nil {
+		return
+	}
+
+	if err := options.Report.Add(entry); err != nil {
+		color.Red(err.Error())
+	}
+}
+
+// Executes a single download attempt which appends to the given partfile, if it already
+// contains data. Returns whether a failed attempt may be retried and how long the server
+// asked us to wait before doing so.
+func downloadPart(downloadLink string, partfile string, max int, current int) (bool, time.Duration, error) {
+	var offset int64 = 0
+	if info, err := os.Stat(partfile); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest("GET", downloadLink, nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("Invalid download link: %w", redactUrl(err))
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	req, timeout := withIdleTimeout(req)
+	defer timeout.Stop()
+
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return errors.New(fmt.Sprintf("Request Failed: %s", err))
+		err = redactUrl(timeout.check(err))
+		return IsRetryableError(err), 0, fmt.Errorf("Request Failed: %w", err)
 	}
 
 	defer resp.Body.Close()
 
-	f, err := os.OpenFile(outfile, os.O_CREATE|os.O_WRONLY, 0644)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server ignored the range request, so we have to start from scratch.
+		offset = 0
+	case http.StatusPartialContent:
+		slog.Debug("Resuming download", "file", partfile, "offset", offset)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The offset is beyond the end of the file, which is only fine if the part file
+		// already contains the whole content. Otherwise the file has changed on the server.
+		if offset == 0 {
+			return false, 0, errors.New(fmt.Sprintf("Request Failed (Code %d)", resp.StatusCode))
+		} else if getCompleteSize(resp, downloadLink) == offset {
+			return false, 0, nil
+		}
+
+		slog.Debug("Partial file does not match the server, restarting download", "file", partfile, "offset", offset)
+		if err := os.Remove(partfile); err != nil {
+			return false, 0, errors.New(fmt.Sprintf("Failed to remove partial file: %s", err))
+		}
+		timeout.Stop()
+		return downloadPart(downloadLink, partfile, max, current)
+	default:
+		err = errors.New(fmt.Sprintf("Request Failed (Code %d)", resp.StatusCode))
+		return IsRetryableStatus(resp.StatusCode), GetRetryAfter(resp), err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(partfile, flags, 0644)
 	if err != nil {
-		return errors.New(fmt.Sprintf("Failed to open file: %s", err))
+		return false, 0, errors.New(fmt.Sprintf("Failed to open file: %s", err))
 	}
 
 	defer f.Close()
 
-	bar := CreatePBar(resp.ContentLength, fmt.Sprintf("downloading %d/%d", current, max))
-	io.Copy(io.MultiWriter(f, bar), resp.Body)
+	length := resp.ContentLength
+	if length > 0 {
+		length += offset
+	}
 
-	return nil
+	bar := CreatePBar(length, fmt.Sprintf("downloading %d/%d", current, max))
+	bar.Set64(offset)
+
+	var body io.Reader = timeout.Reader(resp.Body)
+	if rateLimiter != nil {
+		body = rateLimiter.Reader(body)
+	}
+
+	guard := &spaceGuardWriter{writer: f, directory: filepath.Dir(partfile)}
+	written, err := io.Copy(io.MultiWriter(guard, bar), body)
+	if errors.Is(err, ErrNotEnoughSpace) {
+		return false, 0, fmt.Errorf("Download aborted, the partial file is kept to resume later: %w", err)
+	} else if err != nil {
+		return IsRetryableError(err), 0, fmt.Errorf("Download interrupted: %w", redactUrl(err))
+	}
+
+	if resp.ContentLength > 0 && written < resp.ContentLength {
+		return true, 0, fmt.Errorf("Download interrupted after %d of %d bytes: %w", written, resp.ContentLength, io.ErrUnexpectedEOF)
+	}
+
+	return false, 0, nil
+}
+
+// Removes the query string from the URL in request errors, since download links contain the
+// access token and errors end up in the output and the download manifest.
+func redactUrl(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	redacted := *urlErr
+	redacted.URL = "(invalid URL)"
+	if parsed, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+		parsed.RawQuery = ""
+		redacted.URL = parsed.String()
+	}
+
+	return &redacted
+}
+
+// Returns the size of the whole file for a response to a range request, which is given by
+// its "Content-Range: bytes */size" header. If the server does not send it, the size is
+// requested separately. Returns -1 if the size is unknown.
+func getCompleteSize(resp *http.Response, downloadLink string) int64 {
+	if _, size, found := strings.Cut(resp.Header.Get("Content-Range"), "/"); found {
+		if size, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64); err == nil {
+			return size
+		}
+	}
+
+	head, err := httpClient.Head(downloadLink)
+	if err != nil {
+		return -1
+	}
+	head.Body.Close()
+
+	if head.StatusCode != http.StatusOK {
+		return -1
+	}
+
+	return head.ContentLength
 }
 
 func GetDownloadLinkForId(baseUrl string, token string, id string) string {
 	return fmt.Sprintf(baseUrl+"/Items/%s/Download?api_key=%s", id, token)
 }
 
+// Returns the link to the image of the given type, e.g. "Primary" or "Backdrop".
+func GetImageLinkForId(baseUrl string, token string, id string, imageType string) string {
+	return fmt.Sprintf(baseUrl+"/Items/%s/Images/%s?api_key=%s", id, imageType, token)
+}
+
+// Replaces all characters which are not allowed in file or directory names.
+func SanitizeFilename(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, name)
+
+	sanitized = strings.TrimRight(strings.TrimSpace(sanitized), ".")
+	if sanitized == "" {
+		return "_"
+	}
+
+	return sanitized
+}
+
 func GetSuffixFromFilename(filename string) string {
 	splittedFilename := strings.Split(filename, ".")
 	suffix := splittedFilename[len(splittedFilename)-1]
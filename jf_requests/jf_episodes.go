@@ -1,37 +1,55 @@
 package jf_requests
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
+	"log/slog"
 	"path"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/fatih/color"
 )
 
 type Episode struct {
-	Name        string
-	Id          string
-	Filename    string
-	CanDownload bool
+	Name         string
+	Id           string
+	Filename     string
+	CanDownload  bool
+	MediaSources []MediaSource
+	Metadata     Metadata
+	UserData     UserData
+	// Position of the episode within its season, starting at 1.
+	Number int
 }
 
 type Season struct {
 	Id       string
 	Name     string
 	Episodes []Episode
+	Metadata Metadata
 }
 
 type Series struct {
-	Name    string
-	Id      string
-	Seasons []Season
+	Name     string
+	Id       string
+	Seasons  []Season
+	Metadata Metadata
 }
 
-func GetSeriesFromItem(token string, baseurl string, item *Item) (*Series, error) {
-	requestUrl := fmt.Sprintf("%s/Shows/%s/Episodes?fields=candownload,path", baseurl, item.Id)
+// Returns the series with all its episodes. The metadata of the series, its seasons and
+// episodes is only requested if withMetadata is set, since it is only needed for nfo files
+// and artwork.
+func GetSeriesFromItem(auth *AuthResponse, baseurl string, item *Item, withMetadata bool) (*Series, error) {
+	fields := "candownload,path,mediasources"
+	if withMetadata {
+		fields += "," + MetadataFields
+	}
+	requestUrl := fmt.Sprintf("%s/Shows/%s/Episodes?UserId=%s&fields=%s", baseurl, item.Id, auth.UserId, fields)
 
-	res, err := MakeRequest(token, requestUrl, "GET", nil)
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -63,9 +81,12 @@ func GetSeriesFromItem(token string, baseurl string, item *Item) (*Series, error
 		}
 
 		ep := Episode{
-			Name:        items[index].(map[string]any)["Name"].(string),
-			Id:          items[index].(map[string]any)["Id"].(string),
-			CanDownload: items[index].(map[string]any)["CanDownload"].(bool)}
+			Name:         items[index].(map[string]any)["Name"].(string),
+			Id:           items[index].(map[string]any)["Id"].(string),
+			CanDownload:  items[index].(map[string]any)["CanDownload"].(bool),
+			MediaSources: GetMediaSources(items[index].(map[string]any)),
+			Metadata:     GetMetadata(items[index].(map[string]any)),
+			UserData:     GetUserData(items[index].(map[string]any))}
 
 		if fullpath, pathFieldExists := items[index].(map[string]any)["Path"]; pathFieldExists {
 			fullpath, is_string := fullpath.(string)
@@ -78,6 +99,7 @@ func GetSeriesFromItem(token string, baseurl string, item *Item) (*Series, error
 			continue
 		}
 
+		ep.Number = len(currentSeason.Episodes) + 1
 		currentSeason.Episodes = append(currentSeason.Episodes, ep)
 
 		if seasonId != lastSeasonId {
@@ -97,9 +119,42 @@ func GetSeriesFromItem(token string, baseurl string, item *Item) (*Series, error
 	}
 
 	result.Seasons = seasons
+	if withMetadata {
+		result.loadMetadata(auth.Token, baseurl)
+	}
 	return &result, nil
 }
 
+// Loads the metadata of the series and its seasons. Since the metadata is only needed for
+// nfo files, failures are not considered fatal.
+func (series *Series) loadMetadata(token string, baseurl string) {
+	requestUrl := fmt.Sprintf("%s/Items?Ids=%s&fields=%s", baseurl, series.Id, MetadataFields)
+	if res, err := MakeRequest(token, requestUrl, "GET", nil); err == nil {
+		if items, _ := res["Items"].([]any); len(items) == 1 {
+			series.Metadata = GetMetadata(items[0].(map[string]any))
+		}
+	} else {
+		slog.Debug("Failed to obtain series metadata", "series", series.Name, "error", err)
+	}
+
+	requestUrl = fmt.Sprintf("%s/Shows/%s/Seasons?fields=%s", baseurl, series.Id, MetadataFields)
+	res, err := MakeRequest(token, requestUrl, "GET", nil)
+	if err != nil {
+		slog.Debug("Failed to obtain season metadata", "series", series.Name, "error", err)
+		return
+	}
+
+	rawSeasons, _ := res["Items"].([]any)
+	for _, rawSeason := range rawSeasons {
+		rawSeason := rawSeason.(map[string]any)
+		for idx := range series.Seasons {
+			if series.Seasons[idx].Id == getString(rawSeason, "Id") {
+				series.Seasons[idx].Metadata = GetMetadata(rawSeason)
+			}
+		}
+	}
+}
+
 func (series *Series) GetSeasonForId(seasonId string) (*Season, error) {
 	for _, season := range series.Seasons {
 		if season.Id == seasonId {
@@ -111,70 +166,261 @@ func (series *Series) GetSeasonForId(seasonId string) (*Season, error) {
 }
 
 func (series *Series) PrintAndGetSelection() ([]Season, error) {
-	fmt.Println("Which Season do you want to download:")
+	fmt.Println("Which Seasons do you want to download (e.g. 1,3-5 or !2):")
 
 	color.Cyan("  0. All")
 	for idx, season := range series.Seasons {
 		color.Cyan("  %d. %s", idx+1, season.Name)
 	}
 
-	choice, err := GetUserChoice(len(series.Seasons))
+	choices, err := GetUserChoices(len(series.Seasons), false)
 	if err != nil {
-		return nil, errors.New("Only provide a single number")
+		return nil, errors.New(fmt.Sprintf("Invalid selection: %s", err))
 	}
 
-	if choice == 0 {
-		return series.Seasons, nil
-	} else {
-		return []Season{series.Seasons[choice-1]}, nil
+	var seasons []Season
+	for _, choice := range choices {
+		seasons = append(seasons, series.Seasons[choice-1])
 	}
 
+	return seasons, nil
 }
 
-func (series *Series) PrintAndGetConfirmation(seasonsToDownload []Season) bool {
+// Lets the user pick single episodes of the given seasons. The episodes are numbered
+// continuously across all seasons. Returns the seasons with only the selected episodes,
+// seasons without any selected episode are dropped.
+func (series *Series) PrintAndGetEpisodeSelection(seasons []Season) ([]Season, error) {
+	fmt.Println("Which Episodes do you want to download (e.g. 1,3-5 or !2, press enter for all):")
+
+	count := 0
+	for _, season := range seasons {
+		fmt.Printf("  %s\n", season.Name)
+		for _, episode := range season.Episodes {
+			count++
+			color.Cyan("    %d. E%d %s", count, episode.Number, episode.Name)
+		}
+	}
+
+	choices, err := GetUserChoices(count, true)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid selection: %s", err))
+	}
+
+	var selected []Season
+	number := 0
+	for _, season := range seasons {
+		var episodes []Episode
+		for _, episode := range season.Episodes {
+			number++
+			if slices.Contains(choices, number) {
+				episodes = append(episodes, episode)
+			}
+		}
+
+		if len(episodes) > 0 {
+			season.Episodes = episodes
+			selected = append(selected, season)
+		}
+	}
+
+	return selected, nil
+}
+
+// Prints the episodes which will be downloaded together with their size and asks for
+// confirmation. Returns false without asking, if the episodes do not fit onto the target disk.
+func (series *Series) PrintAndGetConfirmation(options *DownloadOptions, seasonsToDownload []Season) bool {
 	fmt.Println("The following Episodes will be downloaded:")
 	color.Green(series.Name)
-	undownloadbleItemsPresent := false
+	var list confirmationList
+	multipleVersionsPresent := false
+	var totalSize int64
 
 	for season_index, season := range seasonsToDownload {
 		color.Cyan("  └ %d. %s", season_index+1, season.Name)
-		for episode_index, episode := range season.Episodes {
-			outstring := fmt.Sprintf("    └ %d. %s", episode_index+1, episode.Name)
-
-			// Strike out episodes which can not be downloaded from the Jellyfin server due to the CanDownload attribute
-			// set to false
-			if !episode.CanDownload {
-				outstring = fmt.Sprintf("\033[9m%s\033[0m", outstring)
-				undownloadbleItemsPresent = true
+		for _, episode := range season.Episodes {
+			outstring := fmt.Sprintf("    └ %d. %s", episode.Number, episode.Name)
+			if size := options.GetDownloadSize(episode.MediaSources); size > 0 {
+				outstring += fmt.Sprintf(" (%s)", FormatSize(size))
+				if episode.CanDownload {
+					totalSize += size
+				}
+			}
+
+			// Episodes are not asked for a version like movies, so show which one is used.
+			if len(episode.MediaSources) > 1 {
+				multipleVersionsPresent = true
+				if source, _ := SelectMediaSource(episode.MediaSources, options.VersionFilter); source != nil {
+					label := source.GetResolutionLabel()
+					if label == "" {
+						label = source.Name
+					}
+					outstring += fmt.Sprintf(" [%d versions, using %s]", len(episode.MediaSources), label)
+				}
 			}
-			color.Cyan(outstring)
+
+			list.PrintEntry(outstring, episode.CanDownload)
 		}
 	}
 
-	if undownloadbleItemsPresent {
-		color.Yellow("Some items cannot be downloaded due to insufficient permission!")
-		color.Yellow("The affected Items are struck through.")
+	list.PrintWarning()
+
+	if multipleVersionsPresent && options.VersionFilter == "" {
+		color.Yellow("Some episodes have multiple versions, the default version is downloaded.")
+		color.Yellow("Use -version-filter to choose another one.")
+	}
+
+	if !options.CheckFreeSpace(totalSize) {
+		return false
 	}
 
 	return GetConfirmation()
 }
 
-func (season *Season) Download(baseUrl string, token string, keepFilenames bool) {
+// Returns the name of the episode with the given index without suffix, e.g. "S1E2 Name".
+func (season *Season) GetEpisodeName(idx int) string {
+	seasonid := strings.Split(season.Name, " ")
+	return SanitizeFilename(fmt.Sprintf("S%sE%d %s", seasonid[len(seasonid)-1], season.Episodes[idx].Number, season.Episodes[idx].Name))
+}
+
+// Returns the season which contains the episode with the given id together with the index
+// of the episode within that season.
+func (series *Series) GetEpisodeForId(episodeId string) (*Season, int, error) {
+	for seasonIdx := range series.Seasons {
+		for idx, episode := range series.Seasons[seasonIdx].Episodes {
+			if episode.Id == episodeId {
+				return &series.Seasons[seasonIdx], idx, nil
+			}
+		}
+	}
+
+	return nil, -1, fmt.Errorf("no episode found for id: %s", episodeId)
+}
+
+// Downloads the episode with the given index.
+func (season *Season) DownloadEpisode(baseUrl string, token string, options *DownloadOptions, idx int) error {
+	episode := season.Episodes[idx]
+
+	source, err := SelectMediaSource(episode.MediaSources, options.VersionFilter)
+	if err != nil {
+		color.Yellow("%s: %s, using the default version", episode.Name, err)
+	}
+
+	filename := episode.Filename
+	if source != nil && source.GetFilename() != "" {
+		filename = source.GetFilename()
+	}
+
+	outfilename := options.GetOutputFilename(filename, season.GetEpisodeName(idx))
+
+	downloadLink := options.GetLinkForId(baseUrl, token, episode.Id, source)
+	skipped, err := options.downloadMedia(downloadLink, episode.getReportEntry(), outfilename, options.GetDownloadSize(episode.MediaSources), len(season.Episodes), idx)
+	if err != nil {
+		return err
+	} else if skipped {
+		return nil
+	}
+
+	options.downloadSubtitles(baseUrl, token, episode.Id, source, outfilename)
+
+	options.downloadEpisodeThumb(baseUrl, token, &episode, outfilename)
+
+	if options.WriteNfo {
+		if err := WriteEpisodeNfo(GetNfoFilename(outfilename), &episode.Metadata); err != nil {
+			color.Red(err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Downloads all episodes of the season. Stops and returns the error, if the disk runs out
+// of space, and returns ErrDownloadsFailed, if some of the episodes failed.
+func (season *Season) Download(baseUrl string, token string, options *DownloadOptions) error {
+	failed, err := season.download(baseUrl, token, options)
+	if err == nil && failed > 0 {
+		return downloadsFailedError(failed, len(season.Episodes))
+	}
+
+	return err
+}
+
+// Downloads all episodes of the season and returns the number of failed episodes.
+func (season *Season) download(baseUrl string, token string, options *DownloadOptions) (int, error) {
+	failed := 0
 	for idx, episode := range season.Episodes {
 		if episode.CanDownload {
-			var outfilename string
-			if keepFilenames {
-				outfilename = episode.Filename
-			} else {
-				suffix := GetSuffixFromFilename(episode.Filename)
-				seasonid := strings.Split(season.Name, " ")
-				outfilename = fmt.Sprintf("S%sE%d %s.%s", seasonid[len(seasonid)-1], int(idx)+1, episode.Name, suffix)
+			if err := season.DownloadEpisode(baseUrl, token, options, idx); err != nil {
+				color.Red("Failed to download \"%s\": %s", episode.Name, err)
+				if errors.Is(err, ErrNotEnoughSpace) {
+					return failed, err
+				}
+				failed++
 			}
-
-			downloadLink := GetDownloadLinkForId(baseUrl, token, episode.Id)
-			DownloadFromUrl(downloadLink, episode.Name, outfilename, len(season.Episodes), idx)
 		} else {
 			color.Yellow("Skipping non downloadable item: %s", episode.Name)
 		}
 	}
+
+	return failed, nil
+}
+
+// Downloads the given seasons of the series. If requested, the nfo files and artwork of the
+// series and the seasons are written as well. In that case, the series is stored in the
+// layout expected by Kodi: a directory for the series with the tvshow.nfo and one directory
+// per season with its episodes and the season.nfo. Episodes which already exist in the other
+// layout are not downloaded again. Stops and returns the error, if the disk runs out of space,
+// and returns ErrDownloadsFailed, if some of the episodes failed.
+func (series *Series) Download(baseUrl string, token string, options *DownloadOptions, seasons []Season) error {
+	seriesDir := options.OutputDir
+	if !options.InSeriesDirectory {
+		seriesDir = options.GetOutputPath(SanitizeFilename(series.Name))
+	}
+
+	seriesOptions := *options
+	if options.NeedsMetadata() {
+		seriesOptions.OutputDir = seriesDir
+	}
+
+	if options.WriteNfo {
+		if err := WriteShowNfo(seriesOptions.GetOutputPath("tvshow.nfo"), &series.Metadata); err != nil {
+			color.Red(err.Error())
+		}
+	}
+
+	seriesOptions.downloadSeriesArtwork(baseUrl, token, series)
+
+	failed, total := 0, 0
+	for _, season := range seasons {
+		seasonOptions := seriesOptions
+		seasonDir := filepath.Join(seriesDir, SanitizeFilename(season.Name))
+		if options.NeedsMetadata() {
+			seasonOptions.OutputDir = seasonDir
+			seasonOptions.alternativeOutputDir = cmp.Or(options.OutputDir, ".")
+		} else {
+			seasonOptions.alternativeOutputDir = seasonDir
+		}
+
+		if options.WriteNfo && season.Metadata.Title != "" {
+			if err := WriteSeasonNfo(seasonOptions.GetOutputPath("season.nfo"), &season.Metadata); err != nil {
+				color.Red(err.Error())
+			}
+		}
+
+		if season.Metadata.Title != "" {
+			seriesOptions.downloadSeasonArtwork(baseUrl, token, &season)
+		}
+
+		seasonFailed, err := season.download(baseUrl, token, &seasonOptions)
+		failed += seasonFailed
+		total += len(season.Episodes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return downloadsFailedError(failed, total)
+	}
+
+	return nil
 }
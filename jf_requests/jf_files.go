@@ -0,0 +1,102 @@
+package jf_requests
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+// A single downloadable file which is neither an episode nor a movie, e.g. an audio track
+// or a book.
+type MediaFile struct {
+	Name         string
+	Id           string
+	Type         string
+	Filename     string
+	CanDownload  bool
+	IndexNumber  int
+	DiscNumber   int
+	RunTimeTicks int64
+	MediaSources []MediaSource
+}
+
+func parseMediaFile(raw map[string]any) MediaFile {
+	file := MediaFile{
+		Name:         getString(raw, "Name"),
+		Id:           getString(raw, "Id"),
+		Type:         getString(raw, "Type"),
+		CanDownload:  getBool(raw, "CanDownload"),
+		IndexNumber:  int(getInt64(raw, "IndexNumber")),
+		DiscNumber:   int(getInt64(raw, "ParentIndexNumber")),
+		RunTimeTicks: getInt64(raw, "RunTimeTicks"),
+		MediaSources: GetMediaSources(raw),
+	}
+
+	if fullpath := getString(raw, "Path"); fullpath != "" {
+		file.Filename = path.Base(fullpath)
+	}
+
+	return file
+}
+
+func GetMediaFileFromItem(auth *AuthResponse, baseurl string, item *Item) (*MediaFile, error) {
+	requestUrl := fmt.Sprintf("%s/Users/%s/Items/%s", baseurl, auth.UserId, item.Id)
+
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	file := parseMediaFile(res)
+	if file.Filename == "" {
+		return nil, errors.New(fmt.Sprintf("Could not find a file for \"%s\"; Might be missing or corrupted!", file.Name))
+	}
+
+	return &file, nil
+}
+
+// Returns true, if the file is a video which can be transcoded by the server.
+func (file *MediaFile) IsVideo() bool {
+	switch file.Type {
+	case "Episode", "Movie", "Video", "MusicVideo", "Trailer":
+		return true
+	}
+
+	return false
+}
+
+// Downloads the file into outfilename. The suffix of the original file is appended to
+// outfilename, unless the original filenames should be kept. Returns the path of the
+// downloaded file.
+func (file *MediaFile) Download(baseUrl string, token string, options *DownloadOptions, outfilename string) (string, error) {
+	// Only videos can be transcoded, everything else is downloaded as it is.
+	fileOptions := *options
+	if !file.IsVideo() {
+		fileOptions.Transcode = nil
+	}
+
+	source, err := SelectMediaSource(file.MediaSources, options.VersionFilter)
+	if err != nil {
+		source = nil
+	}
+
+	filename := file.Filename
+	if source != nil && source.GetFilename() != "" {
+		filename = source.GetFilename()
+	}
+
+	outfile := fileOptions.GetOutputFilename(filename, outfilename)
+	downloadLink := fileOptions.GetLinkForId(baseUrl, token, file.Id, source)
+	skipped, err := fileOptions.downloadMedia(downloadLink, ReportEntry{Id: file.Id, Name: file.Name, Type: file.Type}, outfile, fileOptions.GetDownloadSize(file.MediaSources), 1, 0)
+	if err != nil {
+		return "", err
+	} else if skipped {
+		return outfile, nil
+	}
+
+	if file.IsVideo() {
+		fileOptions.downloadSubtitles(baseUrl, token, file.Id, source, outfile)
+	}
+
+	return outfile, nil
+}
@@ -0,0 +1,95 @@
+package jf_requests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Image types which are downloaded, mapped to the Kodi/Plex naming of the resulting files.
+var artworkNames = map[string]string{
+	"Primary":  "poster.jpg",
+	"Backdrop": "fanart.jpg",
+	"Logo":     "logo.png",
+	"Thumb":    "landscape.jpg",
+}
+
+// Downloads the image of the given type into outfile. The server converts the image into the
+// format given by the suffix of outfile.
+func DownloadImage(baseUrl string, token string, id string, imageType string, outfile string) error {
+	format := "Jpg"
+	if strings.HasSuffix(outfile, ".png") {
+		format = "Png"
+	}
+
+	link := GetImageLinkForId(baseUrl, token, id, imageType) + "&format=" + format
+	return DownloadFromUrl(link, imageType, outfile, 1, 0)
+}
+
+// Downloads all available artwork of an item into the given directory. Each file is named
+// after the artwork type, prefixed with the given prefix, e.g. "season02-" results in
+// "season02-poster.jpg".
+func (options *DownloadOptions) downloadArtwork(baseUrl string, token string, id string, metadata *Metadata, directory string, prefix string) {
+	if !options.Images {
+		return
+	}
+
+	for _, imageType := range []string{"Primary", "Backdrop", "Logo", "Thumb"} {
+		if !slices.Contains(metadata.ImageTypes, imageType) {
+			continue
+		}
+
+		outfile := filepath.Join(directory, prefix+artworkNames[imageType])
+		if _, err := os.Stat(outfile); err == nil && options.SkipExisting {
+			continue
+		}
+
+		if err := DownloadImage(baseUrl, token, id, imageType, outfile); err != nil {
+			color.Red("Failed to download %s image of \"%s\": %s", imageType, metadata.Title, err)
+		}
+	}
+}
+
+// Downloads the artwork of a movie. Since movies share a directory, the images are named
+// after the movie file, e.g. "Movie-poster.jpg".
+func (options *DownloadOptions) downloadMovieArtwork(baseUrl string, token string, id string, metadata *Metadata, mediafile string) {
+	basename := filepath.Base(mediafile)
+	prefix := strings.TrimSuffix(basename, filepath.Ext(basename)) + "-"
+	options.downloadArtwork(baseUrl, token, id, metadata, filepath.Dir(mediafile), prefix)
+}
+
+// Downloads the artwork of a series into the output directory.
+func (options *DownloadOptions) downloadSeriesArtwork(baseUrl string, token string, series *Series) {
+	options.downloadArtwork(baseUrl, token, series.Id, &series.Metadata, options.OutputDir, "")
+}
+
+// Downloads the artwork of a season, e.g. "season02-poster.jpg".
+func (options *DownloadOptions) downloadSeasonArtwork(baseUrl string, token string, season *Season) {
+	prefix := fmt.Sprintf("season%02d-", season.Metadata.IndexNumber)
+	options.downloadArtwork(baseUrl, token, season.Id, &season.Metadata, options.OutputDir, prefix)
+}
+
+// Downloads the thumbnail of an episode, which is named after the episode file,
+// e.g. "S2E5 Name-thumb.jpg".
+func (options *DownloadOptions) downloadEpisodeThumb(baseUrl string, token string, episode *Episode, mediafile string) {
+	if !options.Images {
+		return
+	}
+
+	// The still image of an episode is stored as its primary image.
+	imageType := "Primary"
+	if slices.Contains(episode.Metadata.ImageTypes, "Thumb") {
+		imageType = "Thumb"
+	} else if !slices.Contains(episode.Metadata.ImageTypes, "Primary") {
+		return
+	}
+
+	outfile := strings.TrimSuffix(mediafile, filepath.Ext(mediafile)) + "-thumb.jpg"
+	if err := DownloadImage(baseUrl, token, episode.Id, imageType, outfile); err != nil {
+		color.Red("Failed to download thumbnail of \"%s\": %s", episode.Name, err)
+	}
+}
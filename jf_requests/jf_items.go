@@ -3,13 +3,27 @@ package jf_requests
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"net/url"
+	"regexp"
 )
 
 type Item struct {
-	Name string
-	Id   string
-	Type string
+	Name           string
+	Id             string
+	Type           string
+	ParentId       string
+	SeriesId       string
+	SeasonId       string
+	SeriesName     string
+	ProductionYear int
+	IsFolder       bool
+	// Only known, if the item was requested with the CanDownload field.
+	CanDownload bool
+	// Size, container and codec of the default version. Only known, if the item was
+	// requested with media sources.
+	Size      int64
+	Container string
+	Codec     string
 }
 
 func GetItem(rawItems []any, parentItem *Item) []Item {
@@ -19,6 +33,22 @@ func GetItem(rawItems []any, parentItem *Item) []Item {
 			Name: item.(map[string]any)["Name"].(string),
 			Id:   item.(map[string]any)["Id"].(string),
 			Type: item.(map[string]any)["Id"].(string),
+
+			ParentId: getString(item.(map[string]any), "ParentId"),
+			SeriesId: getString(item.(map[string]any), "SeriesId"),
+			SeasonId: getString(item.(map[string]any), "SeasonId"),
+
+			SeriesName: getString(item.(map[string]any), "SeriesName"),
+
+			ProductionYear: int(getInt64(item.(map[string]any), "ProductionYear")),
+			IsFolder:       getBool(item.(map[string]any), "IsFolder"),
+			CanDownload:    getBool(item.(map[string]any), "CanDownload"),
+		}
+
+		if sources := GetMediaSources(item.(map[string]any)); len(sources) > 0 {
+			itm.Size = sources[0].Size
+			itm.Container = sources[0].Container
+			itm.Codec = sources[0].GetCodec()
 		}
 
 		if itmtype, ok := item.(map[string]any)["Type"].(string); ok {
@@ -60,6 +90,68 @@ func GetItemsForParentId(auth *AuthResponse, baseurl string, parentItem *Item) (
 	return GetItem(items, parentItem), nil
 }
 
+// Returns the children of the given item including their download permission and size,
+// sorted the way they are shown in the Jellyfin web client.
+func GetChildItemsWithDetails(auth *AuthResponse, baseurl string, parentItem *Item) ([]Item, error) {
+	requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items?ParentId=%s&Fields=CanDownload,MediaSources&SortBy=ParentIndexNumber,IndexNumber,SortName", auth.UserId, parentItem.Id)
+
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := res["Items"].([]any)
+	return GetItem(items, parentItem), nil
+}
+
+// Returns the content of all libraries, or only of the library given by the filter, including
+// the download permission and media details of every item.
+func GetLibraryItems(auth *AuthResponse, baseurl string, filter *SearchFilter) ([]Item, error) {
+	var libraries []Item
+	if filter != nil && filter.Library != "" {
+		library, err := GetLibraryForName(auth, baseurl, filter.Library)
+		if err != nil {
+			return nil, err
+		}
+		libraries = []Item{*library}
+	} else {
+		var err error
+		libraries, err = GetRootItems(auth, baseurl)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var items []Item
+	for idx := range libraries {
+		libraryItems, err := GetItemsOfLibrary(auth, baseurl, &libraries[idx], filter)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, libraryItems...)
+	}
+
+	return items, nil
+}
+
+// Returns the content of the given library recursively, including the download permission
+// and media details of every item. The library of the filter is ignored.
+func GetItemsOfLibrary(auth *AuthResponse, baseurl string, library *Item, filter *SearchFilter) ([]Item, error) {
+	if filter == nil {
+		filter = &SearchFilter{}
+	}
+
+	requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items?ParentId=%s&Recursive=true&Fields=CanDownload,MediaSources&SortBy=SeriesSortName,ParentIndexNumber,IndexNumber,SortName&%s", auth.UserId, library.Id, filter.getQuery())
+
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to obtain the content of library \"%s\": %s", library.Name, err))
+	}
+
+	return GetItem(res["Items"].([]any), nil), nil
+}
+
 // Returns all items found on the given jellyfin server.
 func GetAllItems(auth *AuthResponse, baseurl string) ([]Item, error) {
 	rootItems, err := GetRootItems(auth, baseurl)
@@ -81,25 +173,39 @@ func GetAllItems(auth *AuthResponse, baseurl string) ([]Item, error) {
 
 }
 
-// Returns the item whose name includes the given search term.
-func GetItemsForText(auth *AuthResponse, baseUrl string, searchtext string) ([]Item, error) {
-	all, err := GetAllItems(auth, baseUrl)
+// Returns the items whose name matches the given search term, ordered by relevance. Case,
+// diacritics and small typos are ignored. The optional filter restricts the searched items.
+func GetItemsForText(auth *AuthResponse, baseUrl string, searchtext string, filter *SearchFilter) ([]Item, error) {
+	var all []Item
+	var err error
+	if filter.IsEmpty() {
+		all, err = GetAllItems(auth, baseUrl)
+	} else {
+		all, err = GetFilteredItems(auth, baseUrl, filter, searchtext)
+		// The server does not find names with typos, so search again more broadly and let
+		// the fuzzy search pick the matches.
+		if err == nil && len(all) == 0 && searchtext != "" && len(filter.Types) > 0 {
+			all, err = GetFilteredItems(auth, baseUrl, filter, filter.getFallbackTerm(searchtext))
+		}
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
-	var results []Item
-	for _, item := range all {
-		if strings.Contains(strings.ToLower(item.Name), strings.ToLower(searchtext)) {
-			results = append(results, item)
-		}
-	}
+	return SearchItems(all, searchtext), nil
+}
 
-	return results, nil
+// Matches Jellyfin item ids, which are GUIDs with or without dashes.
+var itemIdPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)
+
+// Returns whether the given value looks like the id of an item.
+func IsItemId(value string) bool {
+	return itemIdPattern.MatchString(value)
 }
 
 func GetItemForId(auth *AuthResponse, baseurl string, id string) (*Item, error) {
-	requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items/%s", auth.UserId, id)
+	requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items/%s", auth.UserId, url.PathEscape(id))
 	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("Failed to find item with id: %s - %s", id, err))
@@ -109,3 +215,42 @@ func GetItemForId(auth *AuthResponse, baseurl string, id string) (*Item, error)
 	resList[0] = res
 	return &GetItem(resList, nil)[0], nil
 }
+
+// Returns all music albums of the artist with the given id.
+func GetAlbumsForArtistId(auth *AuthResponse, baseurl string, artistId string) ([]Item, error) {
+	requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items?AlbumArtistIds=%s&IncludeItemTypes=MusicAlbum&Recursive=true&SortBy=ProductionYear,SortName", auth.UserId, artistId)
+
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := res["Items"].([]any)
+	return GetItem(items, nil), nil
+}
+
+// Returns the next episode to watch for every series the user is currently watching.
+func GetNextUpItems(auth *AuthResponse, baseurl string) ([]Item, error) {
+	requestUrl := baseurl + fmt.Sprintf("/Shows/NextUp?UserId=%s", auth.UserId)
+
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := res["Items"].([]any)
+	return GetItem(items, nil), nil
+}
+
+// Returns all episodes and movies which were started but not finished by the user.
+func GetResumeItems(auth *AuthResponse, baseurl string) ([]Item, error) {
+	requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items/Resume?MediaTypes=Video", auth.UserId)
+
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := res["Items"].([]any)
+	return GetItem(items, nil), nil
+}
@@ -0,0 +1,113 @@
+package jf_requests
+
+// Single video, audio or subtitle stream within a media source.
+type MediaStream struct {
+	Index                int
+	Type                 string
+	Codec                string
+	Language             string
+	DisplayTitle         string
+	IsExternal           bool
+	IsForced             bool
+	IsTextSubtitleStream bool
+	Width                int
+	Height               int
+}
+
+// A single version of an item as it is stored on the server.
+type MediaSource struct {
+	Id           string
+	Name         string
+	Container    string
+	Path         string
+	Size         int64
+	MediaStreams []MediaStream
+}
+
+func getString(raw map[string]any, key string) string {
+	value, _ := raw[key].(string)
+	return value
+}
+
+func getBool(raw map[string]any, key string) bool {
+	value, _ := raw[key].(bool)
+	return value
+}
+
+// Numbers are always decoded as float64 by the json package.
+func getInt64(raw map[string]any, key string) int64 {
+	value, _ := raw[key].(float64)
+	return int64(value)
+}
+
+// Parses the MediaSources field of the given raw item. Returns an empty list, if the item
+// was requested without media sources.
+func GetMediaSources(rawItem map[string]any) []MediaSource {
+	rawSources, _ := rawItem["MediaSources"].([]any)
+
+	sources := make([]MediaSource, 0, len(rawSources))
+	for _, rawSource := range rawSources {
+		source, ok := rawSource.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		mediaSource := MediaSource{
+			Id:        getString(source, "Id"),
+			Name:      getString(source, "Name"),
+			Container: getString(source, "Container"),
+			Path:      getString(source, "Path"),
+			Size:      getInt64(source, "Size"),
+		}
+
+		rawStreams, _ := source["MediaStreams"].([]any)
+		for _, rawStream := range rawStreams {
+			stream, ok := rawStream.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			mediaSource.MediaStreams = append(mediaSource.MediaStreams, MediaStream{
+				Index:                int(getInt64(stream, "Index")),
+				Type:                 getString(stream, "Type"),
+				Codec:                getString(stream, "Codec"),
+				Language:             getString(stream, "Language"),
+				DisplayTitle:         getString(stream, "DisplayTitle"),
+				IsExternal:           getBool(stream, "IsExternal"),
+				IsForced:             getBool(stream, "IsForced"),
+				IsTextSubtitleStream: getBool(stream, "IsTextSubtitleStream"),
+				Width:                int(getInt64(stream, "Width")),
+				Height:               int(getInt64(stream, "Height")),
+			})
+		}
+
+		sources = append(sources, mediaSource)
+	}
+
+	return sources
+}
+
+// Returns all streams of the given type, e.g. "Subtitle".
+func (source *MediaSource) GetStreamsOfType(streamType string) []MediaStream {
+	var streams []MediaStream
+	for _, stream := range source.MediaStreams {
+		if stream.Type == streamType {
+			streams = append(streams, stream)
+		}
+	}
+
+	return streams
+}
+
+// Returns the codec of the video stream or, for audio files, of the first audio stream.
+func (source *MediaSource) GetCodec() string {
+	if video := source.GetVideoStream(); video != nil {
+		return video.Codec
+	}
+
+	if audio := source.GetStreamsOfType("Audio"); len(audio) > 0 {
+		return audio[0].Codec
+	}
+
+	return ""
+}
@@ -14,6 +14,10 @@ type Movie struct {
 	Filename     string
 	CanDownload  bool
 	DownloadLink string
+	MediaSources []MediaSource
+	// Version of the movie which will be downloaded.
+	Source   *MediaSource
+	Metadata Metadata
 }
 
 func GetMovieFromItem(auth *AuthResponse, baseurl string, item *Item) (*Movie, error) {
@@ -35,17 +39,56 @@ func GetMovieFromItem(auth *AuthResponse, baseurl string, item *Item) (*Movie, e
 		Id:           res["Id"].(string),
 		CanDownload:  res["CanDownload"].(bool),
 		Filename:     path.Base(res["Path"].(string)),
-		DownloadLink: ""}
+		DownloadLink: "",
+		MediaSources: GetMediaSources(res),
+		Metadata:     GetMetadata(res)}
 
 	mov.DownloadLink = GetDownloadLinkForId(baseurl, auth.Token, mov.Id)
+	mov.Source, _ = SelectMediaSource(mov.MediaSources, "")
 
 	return &mov, nil
 }
 
-func (movie *Movie) PrintAndGetConfirmation() bool {
+// Selects the version of the movie which should be downloaded. If the movie has multiple
+// versions and no filter is given, the user is asked to choose one, unless interactive is
+// false. The default version is used in that case, just like if no version matches the
+// filter.
+func (movie *Movie) SelectVersion(filter string, interactive bool) error {
+	if len(movie.MediaSources) < 2 || (filter == "" && !interactive) {
+		return nil
+	}
+
+	var source *MediaSource
+	var err error
+	if filter == "" {
+		source, err = PrintAndGetVersionSelection(movie.MediaSources)
+		if err != nil {
+			return err
+		}
+	} else if source, err = SelectMediaSource(movie.MediaSources, filter); err != nil {
+		color.Yellow("%s: %s, using the default version", movie.Name, err)
+	}
+
+	movie.Source = source
+	return nil
+}
+
+func (movie *Movie) PrintAndGetConfirmation(options *DownloadOptions) bool {
 	if movie.CanDownload {
 		fmt.Println("The following Movie will be downloaded:")
 		color.Green("Name: %s", movie.Name)
+		if len(movie.MediaSources) > 1 && movie.Source != nil {
+			color.Green("Version: %s", movie.Source.Describe())
+		}
+
+		var size int64
+		if movie.Source != nil && options.Transcode == nil {
+			size = movie.Source.Size
+		}
+
+		if !options.CheckFreeSpace(size) {
+			return false
+		}
 
 		return GetConfirmation()
 	} else {
@@ -54,15 +97,38 @@ func (movie *Movie) PrintAndGetConfirmation() bool {
 	}
 }
 
-func (movie *Movie) Download(keepFilename bool) {
-	var outfilename string
-	if keepFilename {
-		outfilename = movie.Filename
-	} else {
-		suffix := GetSuffixFromFilename(movie.Filename)
-		fmt.Println(suffix)
-		outfilename = fmt.Sprintf("%s.%s", movie.Name, suffix)
+// Downloads the selected version of the movie together with the requested subtitles,
+// artwork and nfo file.
+func (movie *Movie) Download(baseUrl string, token string, options *DownloadOptions) error {
+	filename := movie.Filename
+	if movie.Source != nil && movie.Source.GetFilename() != "" {
+		filename = movie.Source.GetFilename()
+	}
+	outfilename := options.GetOutputFilename(filename, SanitizeFilename(movie.Name))
+
+	downloadLink := options.GetLinkForId(baseUrl, token, movie.Id, movie.Source)
+
+	var expectedSize int64
+	if movie.Source != nil && options.Transcode == nil {
+		expectedSize = movie.Source.Size
+	}
+
+	skipped, err := options.downloadMedia(downloadLink, ReportEntry{Id: movie.Id, Name: movie.Name, Type: "Movie"}, outfilename, expectedSize, 1, 0)
+	if err != nil {
+		return err
+	} else if skipped {
+		return nil
+	}
+
+	options.downloadSubtitles(baseUrl, token, movie.Id, movie.Source, outfilename)
+
+	options.downloadMovieArtwork(baseUrl, token, movie.Id, &movie.Metadata, outfilename)
+
+	if options.WriteNfo {
+		if err := WriteMovieNfo(GetNfoFilename(outfilename), &movie.Metadata); err != nil {
+			color.Red(err.Error())
+		}
 	}
 
-	DownloadFromUrl(movie.DownloadLink, movie.Name, outfilename, 1, 0)
+	return nil
 }
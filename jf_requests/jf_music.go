@@ -0,0 +1,141 @@
+package jf_requests
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/fatih/color"
+)
+
+type Album struct {
+	Name     string
+	Id       string
+	Artist   string
+	HasCover bool
+	Tracks   []MediaFile
+}
+
+// Returns true, if the given raw item has an image of the given type.
+func hasImage(raw map[string]any, imageType string) bool {
+	tags, _ := raw["ImageTags"].(map[string]any)
+	_, ok := tags[imageType]
+	return ok
+}
+
+func GetAlbumFromItem(auth *AuthResponse, baseurl string, item *Item) (*Album, error) {
+	requestUrl := fmt.Sprintf("%s/Users/%s/Items/%s", baseurl, auth.UserId, item.Id)
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	album := Album{
+		Name:     getString(res, "Name"),
+		Id:       getString(res, "Id"),
+		Artist:   getString(res, "AlbumArtist"),
+		HasCover: hasImage(res, "Primary"),
+	}
+
+	if album.Artist == "" {
+		album.Artist = "Unknown Artist"
+	}
+
+	requestUrl = fmt.Sprintf("%s/Users/%s/Items?ParentId=%s&IncludeItemTypes=Audio&Recursive=true&Fields=Path,CanDownload,MediaSources&SortBy=ParentIndexNumber,IndexNumber,SortName", baseurl, auth.UserId, item.Id)
+	res, err = MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rawTrack := range res["Items"].([]any) {
+		album.Tracks = append(album.Tracks, parseMediaFile(rawTrack.(map[string]any)))
+	}
+
+	return &album, nil
+}
+
+// Returns the directory in which the album is stored, e.g. "Artist/Album".
+func (album *Album) GetDirectory() string {
+	return filepath.Join(SanitizeFilename(album.Artist), SanitizeFilename(album.Name))
+}
+
+// Returns true, if the tracks of the album are spread over multiple discs.
+func (album *Album) IsMultiDisc() bool {
+	for _, track := range album.Tracks {
+		if track.DiscNumber > 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the name of the given track without suffix, e.g. "01 - Title". For albums with
+// multiple discs, the disc number is prepended: "2-01 - Title".
+func (album *Album) GetTrackName(idx int) string {
+	track := album.Tracks[idx]
+
+	number := track.IndexNumber
+	if number == 0 {
+		number = idx + 1
+	}
+
+	if album.IsMultiDisc() {
+		return fmt.Sprintf("%d-%02d - %s", track.DiscNumber, number, SanitizeFilename(track.Name))
+	}
+
+	return fmt.Sprintf("%02d - %s", number, SanitizeFilename(track.Name))
+}
+
+func (album *Album) PrintAndGetConfirmation() bool {
+	fmt.Println("The following Tracks will be downloaded:")
+	color.Green("%s - %s", album.Artist, album.Name)
+	var list confirmationList
+
+	for idx, track := range album.Tracks {
+		list.PrintEntry(fmt.Sprintf("  └ %s", album.GetTrackName(idx)), track.CanDownload)
+	}
+
+	list.PrintWarning()
+
+	return GetConfirmation()
+}
+
+// Downloads all tracks of the album together with its cover into "Artist/Album". Stops, if the
+// disk runs out of space, and returns ErrDownloadsFailed, if some of the tracks failed.
+func (album *Album) Download(baseUrl string, token string, options *DownloadOptions) error {
+	directory := album.GetDirectory()
+
+	failed := 0
+	for idx, track := range album.Tracks {
+		if !track.CanDownload {
+			color.Yellow("Skipping non downloadable item: %s", track.Name)
+			continue
+		} else if track.Filename == "" {
+			color.Yellow("Did not found a filename for track: \"%s\". It will be ignored..", track.Name)
+			continue
+		}
+
+		outfilename := filepath.Join(directory, album.GetTrackName(idx))
+		if _, err := track.Download(baseUrl, token, options, outfilename); err != nil {
+			color.Red("Failed to download \"%s\": %s", track.Name, err)
+			if errors.Is(err, ErrNotEnoughSpace) {
+				return err
+			}
+			failed++
+		}
+	}
+
+	if album.HasCover {
+		coverLink := GetImageLinkForId(baseUrl, token, album.Id, "Primary")
+		if err := DownloadFromUrl(coverLink, "cover", options.GetOutputPath(filepath.Join(directory, "cover.jpg")), 1, 0); err != nil {
+			color.Red("Failed to download the cover of \"%s\": %s", album.Name, err)
+		}
+	}
+
+	if failed > 0 {
+		return downloadsFailedError(failed, len(album.Tracks))
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package jf_requests
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Fields which are requested for items whose metadata should be written into nfo files.
+const MetadataFields = "overview,genres,people,providerids,studios"
+
+type Person struct {
+	Name string
+	Role string
+	Type string
+}
+
+// Descriptive metadata of an item as returned by the Jellyfin server.
+type Metadata struct {
+	Title          string
+	OriginalTitle  string
+	SeriesName     string
+	Overview       string
+	PremiereDate   string
+	ProductionYear int
+	IndexNumber    int
+	SeasonNumber   int
+	// Whether the server provided the index and season number, since 0 is a valid value.
+	HasIndexNumber  bool
+	HasSeasonNumber bool
+	CommunityRating float64
+	OfficialRating  string
+	RunTimeTicks    int64
+	Genres          []string
+	Studios         []string
+	People          []Person
+	ProviderIds     map[string]string
+	// Types of the images which are available for the item, e.g. "Primary" or "Backdrop".
+	ImageTypes []string
+}
+
+func getStringList(raw map[string]any, key string) []string {
+	rawList, _ := raw[key].([]any)
+
+	var list []string
+	for _, value := range rawList {
+		switch value := value.(type) {
+		case string:
+			list = append(list, value)
+		case map[string]any:
+			// Studios are returned as name/id pairs.
+			if name := getString(value, "Name"); name != "" {
+				list = append(list, name)
+			}
+		}
+	}
+
+	return list
+}
+
+// Parses the metadata fields of the given raw item.
+func GetMetadata(raw map[string]any) Metadata {
+	metadata := Metadata{
+		Title:          getString(raw, "Name"),
+		OriginalTitle:  getString(raw, "OriginalTitle"),
+		SeriesName:     getString(raw, "SeriesName"),
+		Overview:       getString(raw, "Overview"),
+		ProductionYear: int(getInt64(raw, "ProductionYear")),
+		IndexNumber:    int(getInt64(raw, "IndexNumber")),
+		SeasonNumber:   int(getInt64(raw, "ParentIndexNumber")),
+		OfficialRating: getString(raw, "OfficialRating"),
+		RunTimeTicks:   getInt64(raw, "RunTimeTicks"),
+		Genres:         getStringList(raw, "Genres"),
+		Studios:        getStringList(raw, "Studios"),
+		ProviderIds:    make(map[string]string),
+	}
+
+	metadata.CommunityRating, _ = raw["CommunityRating"].(float64)
+	_, metadata.HasIndexNumber = raw["IndexNumber"].(float64)
+	_, metadata.HasSeasonNumber = raw["ParentIndexNumber"].(float64)
+
+	// Only keep the date part of e.g. "2008-01-20T00:00:00.0000000Z"
+	if premiere := getString(raw, "PremiereDate"); len(premiere) >= 10 {
+		metadata.PremiereDate = premiere[:10]
+	}
+
+	rawPeople, _ := raw["People"].([]any)
+	for _, rawPerson := range rawPeople {
+		if person, ok := rawPerson.(map[string]any); ok {
+			metadata.People = append(metadata.People, Person{
+				Name: getString(person, "Name"),
+				Role: getString(person, "Role"),
+				Type: getString(person, "Type"),
+			})
+		}
+	}
+
+	rawTags, _ := raw["ImageTags"].(map[string]any)
+	for imageType := range rawTags {
+		metadata.ImageTypes = append(metadata.ImageTypes, imageType)
+	}
+
+	if backdrops, _ := raw["BackdropImageTags"].([]any); len(backdrops) > 0 {
+		metadata.ImageTypes = append(metadata.ImageTypes, "Backdrop")
+	}
+
+	rawIds, _ := raw["ProviderIds"].(map[string]any)
+	for provider, id := range rawIds {
+		if id, ok := id.(string); ok && id != "" {
+			metadata.ProviderIds[strings.ToLower(provider)] = id
+		}
+	}
+
+	return metadata
+}
+
+type nfoActor struct {
+	Name string `xml:"name"`
+	Role string `xml:"role,omitempty"`
+}
+
+type nfoUniqueId struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+// Kodi compatible nfo document. The root element is set through XMLName, so the same
+// structure is used for movies, shows, seasons and episodes.
+type nfoDocument struct {
+	XMLName       xml.Name
+	Title         string        `xml:"title"`
+	OriginalTitle string        `xml:"originaltitle,omitempty"`
+	ShowTitle     string        `xml:"showtitle,omitempty"`
+	SeasonNumber  *int          `xml:"seasonnumber,omitempty"`
+	Season        *int          `xml:"season,omitempty"`
+	Episode       *int          `xml:"episode,omitempty"`
+	Plot          string        `xml:"plot,omitempty"`
+	Premiered     string        `xml:"premiered,omitempty"`
+	Aired         string        `xml:"aired,omitempty"`
+	Year          int           `xml:"year,omitempty"`
+	Rating        float64       `xml:"rating,omitempty"`
+	Mpaa          string        `xml:"mpaa,omitempty"`
+	Runtime       int64         `xml:"runtime,omitempty"`
+	Genres        []string      `xml:"genre"`
+	Studios       []string      `xml:"studio"`
+	Directors     []string      `xml:"director"`
+	Credits       []string      `xml:"credits"`
+	UniqueIds     []nfoUniqueId `xml:"uniqueid"`
+	Actors        []nfoActor    `xml:"actor"`
+}
+
+func newNfoDocument(root string, metadata *Metadata) *nfoDocument {
+	doc := &nfoDocument{
+		XMLName:       xml.Name{Local: root},
+		Title:         metadata.Title,
+		OriginalTitle: metadata.OriginalTitle,
+		Plot:          metadata.Overview,
+		Year:          metadata.ProductionYear,
+		Rating:        metadata.CommunityRating,
+		Mpaa:          metadata.OfficialRating,
+		Genres:        metadata.Genres,
+		Studios:       metadata.Studios,
+	}
+
+	if metadata.OriginalTitle == metadata.Title {
+		doc.OriginalTitle = ""
+	}
+
+	// RunTimeTicks are given in units of 100ns, Kodi expects minutes.
+	doc.Runtime = metadata.RunTimeTicks / 10_000_000 / 60
+
+	for _, person := range metadata.People {
+		switch person.Type {
+		case "Actor", "GuestStar":
+			doc.Actors = append(doc.Actors, nfoActor{Name: person.Name, Role: person.Role})
+		case "Director":
+			doc.Directors = append(doc.Directors, person.Name)
+		case "Writer":
+			doc.Credits = append(doc.Credits, person.Name)
+		}
+	}
+
+	providers := make([]string, 0, len(metadata.ProviderIds))
+	for provider := range metadata.ProviderIds {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	for _, provider := range providers {
+		doc.UniqueIds = append(doc.UniqueIds, nfoUniqueId{
+			Type:    provider,
+			Default: provider == "imdb" || (provider == "tvdb" && metadata.ProviderIds["imdb"] == ""),
+			Value:   metadata.ProviderIds[provider],
+		})
+	}
+
+	return doc
+}
+
+func writeNfo(outfile string, doc *nfoDocument) error {
+	content, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return errors.New(fmt.Sprintf("Failed to create nfo file: %s", err))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outfile), 0755); err != nil {
+		return errors.New(fmt.Sprintf("Failed to create directory: %s", err))
+	}
+
+	content = append([]byte(xml.Header), content...)
+	if err := os.WriteFile(outfile, append(content, '\n'), 0644); err != nil {
+		return errors.New(fmt.Sprintf("Failed to write nfo file: %s", err))
+	}
+
+	return nil
+}
+
+// Returns the path of the nfo file which belongs to the given media file.
+func GetNfoFilename(mediafile string) string {
+	return strings.TrimSuffix(mediafile, filepath.Ext(mediafile)) + ".nfo"
+}
+
+func WriteMovieNfo(outfile string, metadata *Metadata) error {
+	doc := newNfoDocument("movie", metadata)
+	doc.Premiered = metadata.PremiereDate
+	return writeNfo(outfile, doc)
+}
+
+func WriteShowNfo(outfile string, metadata *Metadata) error {
+	doc := newNfoDocument("tvshow", metadata)
+	doc.Premiered = metadata.PremiereDate
+	return writeNfo(outfile, doc)
+}
+
+func WriteSeasonNfo(outfile string, metadata *Metadata) error {
+	doc := newNfoDocument("season", metadata)
+	doc.Premiered = metadata.PremiereDate
+	doc.SeasonNumber = &metadata.IndexNumber
+	return writeNfo(outfile, doc)
+}
+
+func WriteEpisodeNfo(outfile string, metadata *Metadata) error {
+	doc := newNfoDocument("episodedetails", metadata)
+	doc.ShowTitle = metadata.SeriesName
+	doc.Season = &metadata.SeasonNumber
+	doc.Episode = &metadata.IndexNumber
+	doc.Aired = metadata.PremiereDate
+	return writeNfo(outfile, doc)
+}
@@ -0,0 +1,123 @@
+package jf_requests
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+type Playlist struct {
+	Name    string
+	Id      string
+	Entries []MediaFile
+}
+
+// Returns all playlists whose name contains the given search term.
+func GetPlaylistsForText(auth *AuthResponse, baseurl string, searchtext string) ([]Item, error) {
+	requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items?IncludeItemTypes=Playlist&Recursive=true&SearchTerm=%s", auth.UserId, url.QueryEscape(searchtext))
+
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := res["Items"].([]any)
+	return GetItem(items, nil), nil
+}
+
+func GetPlaylistFromItem(auth *AuthResponse, baseurl string, item *Item) (*Playlist, error) {
+	requestUrl := fmt.Sprintf("%s/Playlists/%s/Items?UserId=%s&Fields=Path,CanDownload,MediaSources", baseurl, item.Id, auth.UserId)
+
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to obtain the entries of playlist \"%s\": %s", item.Name, err))
+	}
+
+	playlist := Playlist{
+		Name: item.Name,
+		Id:   item.Id,
+	}
+
+	for _, rawEntry := range res["Items"].([]any) {
+		playlist.Entries = append(playlist.Entries, parseMediaFile(rawEntry.(map[string]any)))
+	}
+
+	return &playlist, nil
+}
+
+// Returns the name of the given entry without suffix. The entries are numbered, so that the
+// order of the playlist is kept when the files are sorted by name.
+func (playlist *Playlist) GetEntryName(idx int) string {
+	width := len(fmt.Sprint(len(playlist.Entries)))
+	if width < 2 {
+		width = 2
+	}
+
+	return fmt.Sprintf("%0*d - %s", width, idx+1, SanitizeFilename(playlist.Entries[idx].Name))
+}
+
+func (playlist *Playlist) PrintAndGetConfirmation() bool {
+	fmt.Println("The following Playlist Entries will be downloaded:")
+	color.Green(playlist.Name)
+	var list confirmationList
+
+	for idx, entry := range playlist.Entries {
+		list.PrintEntry(fmt.Sprintf("  └ %s (%s)", playlist.GetEntryName(idx), entry.Type), entry.CanDownload)
+	}
+
+	list.PrintWarning()
+
+	return GetConfirmation()
+}
+
+// Downloads all entries of the playlist into a directory named after the playlist and writes
+// an m3u8 file which references the downloaded files.
+func (playlist *Playlist) Download(baseUrl string, token string, options *DownloadOptions) error {
+	directory := SanitizeFilename(playlist.Name)
+
+	var m3u strings.Builder
+	m3u.WriteString("#EXTM3U\n")
+
+	for idx, entry := range playlist.Entries {
+		if !entry.CanDownload {
+			color.Yellow("Skipping non downloadable item: %s", entry.Name)
+			continue
+		} else if entry.Filename == "" {
+			color.Yellow("Did not found a filename for entry: \"%s\". It will be ignored..", entry.Name)
+			continue
+		}
+
+		outfile, err := entry.Download(baseUrl, token, options, filepath.Join(directory, playlist.GetEntryName(idx)))
+		if errors.Is(err, ErrNotEnoughSpace) {
+			return err
+		} else if err != nil {
+			color.Red("Failed to download \"%s\": %s", entry.Name, err)
+			continue
+		}
+
+		// RunTimeTicks are given in units of 100ns.
+		seconds := entry.RunTimeTicks / 10_000_000
+		if seconds == 0 {
+			seconds = -1
+		}
+
+		relative, _ := filepath.Rel(options.GetOutputPath(directory), outfile)
+		fmt.Fprintf(&m3u, "#EXTINF:%d,%s\n%s\n", seconds, entry.Name, filepath.ToSlash(relative))
+	}
+
+	m3ufile := options.GetOutputPath(filepath.Join(directory, SanitizeFilename(playlist.Name)+".m3u8"))
+	if err := os.MkdirAll(filepath.Dir(m3ufile), 0755); err != nil {
+		return errors.New(fmt.Sprintf("Failed to create directory: %s", err))
+	}
+
+	if err := os.WriteFile(m3ufile, []byte(m3u.String()), 0644); err != nil {
+		return errors.New(fmt.Sprintf("Failed to write playlist file: %s", err))
+	}
+
+	return nil
+}
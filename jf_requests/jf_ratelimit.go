@@ -0,0 +1,143 @@
+package jf_requests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Time window in which the download rate is limited. Outside of this window, downloads
+// run at full speed. If Start is after End, the window wraps around midnight.
+type RateSchedule struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Token bucket which limits the number of bytes per second over all readers created from it.
+// A single limiter can be shared between multiple parallel downloads.
+type RateLimiter struct {
+	BytesPerSecond int64
+	Schedule       *RateSchedule
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+var rateLimiter *RateLimiter
+
+// Sets the limiter which is applied to all downloads. Pass nil to disable the rate limit.
+func SetRateLimiter(limiter *RateLimiter) {
+	rateLimiter = limiter
+}
+
+func NewRateLimiter(bytesPerSecond int64, schedule *RateSchedule) *RateLimiter {
+	return &RateLimiter{
+		BytesPerSecond: bytesPerSecond,
+		Schedule:       schedule,
+		last:           time.Now(),
+	}
+}
+
+// Parses a schedule in the format "HH:MM-HH:MM", e.g. "08:00-22:00".
+func ParseRateSchedule(schedule string) (*RateSchedule, error) {
+	parts := strings.Split(schedule, "-")
+	if len(parts) != 2 {
+		return nil, errors.New(fmt.Sprintf("invalid schedule \"%s\", expected HH:MM-HH:MM", schedule))
+	}
+
+	var bounds [2]time.Duration
+	for idx, part := range parts {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid time \"%s\" in schedule, expected HH:MM", part))
+		}
+		bounds[idx] = time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
+	}
+
+	return &RateSchedule{Start: bounds[0], End: bounds[1]}, nil
+}
+
+// Returns true, if the given point in time lies within the schedule.
+func (schedule *RateSchedule) Contains(t time.Time) bool {
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+	if schedule.Start <= schedule.End {
+		return sinceMidnight >= schedule.Start && sinceMidnight < schedule.End
+	}
+
+	return sinceMidnight >= schedule.Start || sinceMidnight < schedule.End
+}
+
+// Returns the currently effective rate in bytes per second. 0 means unlimited.
+func (limiter *RateLimiter) currentRate(now time.Time) int64 {
+	if limiter.Schedule != nil && !limiter.Schedule.Contains(now) {
+		return 0
+	}
+
+	return limiter.BytesPerSecond
+}
+
+// Takes n bytes out of the bucket and blocks until they are available.
+func (limiter *RateLimiter) wait(n int) {
+	limiter.mutex.Lock()
+
+	now := time.Now()
+	rate := limiter.currentRate(now)
+	if rate <= 0 {
+		limiter.last = now
+		limiter.mutex.Unlock()
+		return
+	}
+
+	// Refill the bucket. At most one second worth of data may be accumulated.
+	limiter.tokens += now.Sub(limiter.last).Seconds() * float64(rate)
+	if limiter.tokens > float64(rate) {
+		limiter.tokens = float64(rate)
+	}
+	limiter.last = now
+
+	// Reserve the tokens right away, so that concurrent readers queue up behind us.
+	limiter.tokens -= float64(n)
+	var delay time.Duration
+	if limiter.tokens < 0 {
+		delay = time.Duration(-limiter.tokens / float64(rate) * float64(time.Second))
+	}
+
+	limiter.mutex.Unlock()
+
+	time.Sleep(delay)
+}
+
+type rateLimitedReader struct {
+	reader  io.Reader
+	limiter *RateLimiter
+}
+
+// Wraps the given reader, so that reading from it does not exceed the limit.
+func (limiter *RateLimiter) Reader(reader io.Reader) io.Reader {
+	return &rateLimitedReader{reader: reader, limiter: limiter}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// Read in small chunks, so that the bandwidth is shared fairly and the rate stays smooth.
+	if rate := r.limiter.currentRate(time.Now()); rate > 0 {
+		chunk := int(rate / 10)
+		if chunk < 1024 {
+			chunk = 1024
+		}
+		if len(p) > chunk {
+			p = p[:chunk]
+		}
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+
+	return n, err
+}
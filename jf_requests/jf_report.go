@@ -0,0 +1,100 @@
+package jf_requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Name of the file in the output directory which lists all downloads of a run.
+const ReportFilename = "download-manifest.json"
+
+// Status of a media file within the download report.
+const (
+	StatusDownloaded = "downloaded"
+	StatusSkipped    = "skipped"
+	StatusFailed     = "failed"
+)
+
+// A single media file which was attempted to download.
+type ReportEntry struct {
+	Id            string `json:"id"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	SeriesName    string `json:"seriesName,omitempty"`
+	SeasonNumber  *int   `json:"seasonNumber,omitempty"`
+	EpisodeNumber *int   `json:"episodeNumber,omitempty"`
+	Path          string `json:"path"`
+	// Size of the file. For failed downloads, the size of the partial file.
+	Bytes int64 `json:"bytes"`
+	// Duration of the download and the average speed of the bytes transferred during it.
+	DurationSeconds float64 `json:"durationSeconds"`
+	BytesPerSecond  int64   `json:"bytesPerSecond"`
+	Sha256          string  `json:"sha256,omitempty"`
+	Status          string  `json:"status"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// Machine readable report of all media files of a run, which is written into the output
+// directory after every file, so that it is complete even if the run is interrupted.
+type DownloadReport struct {
+	Directory string        `json:"-"`
+	Started   time.Time     `json:"started"`
+	Updated   time.Time     `json:"updated"`
+	Items     []ReportEntry `json:"items"`
+}
+
+func NewDownloadReport(directory string) *DownloadReport {
+	if directory == "" {
+		directory = "."
+	}
+
+	return &DownloadReport{Directory: directory, Started: time.Now(), Items: []ReportEntry{}}
+}
+
+// Adds the entry to the report and saves it.
+func (report *DownloadReport) Add(entry ReportEntry) error {
+	report.Items = append(report.Items, entry)
+	report.Updated = time.Now()
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.New(fmt.Sprintf("Failed to create download manifest: %s", err))
+	}
+
+	if err := os.MkdirAll(report.Directory, 0755); err != nil {
+		return errors.New(fmt.Sprintf("Failed to create directory: %s", err))
+	}
+
+	outfile := filepath.Join(report.Directory, ReportFilename)
+	if err := os.WriteFile(outfile+".tmp", append(content, '\n'), 0644); err != nil {
+		return errors.New(fmt.Sprintf("Failed to write download manifest: %s", err))
+	}
+
+	return os.Rename(outfile+".tmp", outfile)
+}
+
+// Returns the report entry of the episode, which is completed by the download.
+func (episode *Episode) getReportEntry() ReportEntry {
+	entry := ReportEntry{
+		Id:         episode.Id,
+		Name:       episode.Name,
+		Type:       "Episode",
+		SeriesName: episode.Metadata.SeriesName,
+	}
+
+	// Leave out numbers the server does not know, instead of reporting them as 0.
+	if episode.Metadata.HasSeasonNumber {
+		seasonNumber := episode.Metadata.SeasonNumber
+		entry.SeasonNumber = &seasonNumber
+	}
+	if episode.Metadata.HasIndexNumber {
+		episodeNumber := episode.Metadata.IndexNumber
+		entry.EpisodeNumber = &episodeNumber
+	}
+
+	return entry
+}
@@ -10,6 +10,7 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type AuthRequestBody struct {
@@ -40,23 +41,9 @@ func ExecuteRequest(request *http.Request) (map[string]any, error) {
 		slog.Debug(fmt.Sprintf("Executing Request against: %s", request.URL), "method", request.Method, "header", headerForPrinting, "body", request.Body)
 	}
 
-	client := &http.Client{}
-	res, err := client.Do(request)
-
+	res, content_raw, err := executeWithRetry(request)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("Request Failed: %s", err))
-	}
-
-	defer res.Body.Close()
-
-	var content_raw []byte
-	content_raw, err = io.ReadAll(res.Body)
-
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("Could not read response body: %s", err))
-	} else if res.StatusCode != 200 {
-		slog.Debug(fmt.Sprintf("Request to %s returned a non 200 response code", request.RequestURI), "code", res.StatusCode, "response", string(content_raw[:]))
-		return nil, errors.New(fmt.Sprintf("Request Failed (Code %d): %s", res.StatusCode, content_raw))
+		return nil, err
 	}
 
 	var content_json map[string]any
@@ -76,6 +63,58 @@ func ExecuteRequest(request *http.Request) (map[string]any, error) {
 
 }
 
+// Executes the given request and returns the response together with its body. Failed
+// requests are repeated according to the configured retry policy, as long as the failure
+// is of transient nature (connection problems, 5xx or 429 status codes).
+func executeWithRetry(request *http.Request) (*http.Response, []byte, error) {
+	for attempt := 1; ; attempt++ {
+		// The body was consumed by the previous attempt and has to be recreated.
+		if attempt > 1 && request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, nil, errors.New(fmt.Sprintf("Failed to recreate request body: %s", err))
+			}
+			request.Body = body
+		}
+
+		var retryAfter time.Duration
+		attemptRequest, timeout := withIdleTimeout(request)
+		res, err := httpClient.Do(attemptRequest)
+		if err != nil {
+			timeout.Stop()
+			err = fmt.Errorf("Request Failed: %w", redactUrl(timeout.check(err)))
+			if attempt >= retryPolicy.MaxAttempts || !IsRetryableError(err) {
+				return nil, nil, err
+			}
+		} else {
+			var content_raw []byte
+			content_raw, err = io.ReadAll(timeout.Reader(res.Body))
+			res.Body.Close()
+			timeout.Stop()
+
+			if err != nil {
+				err = fmt.Errorf("Could not read response body: %w", err)
+				if attempt >= retryPolicy.MaxAttempts || !IsRetryableError(err) {
+					return nil, nil, err
+				}
+			} else if res.StatusCode != 200 {
+				slog.Debug(fmt.Sprintf("Request to %s returned a non 200 response code", request.RequestURI), "code", res.StatusCode, "response", string(content_raw[:]))
+				err = errors.New(fmt.Sprintf("Request Failed (Code %d): %s", res.StatusCode, content_raw))
+				if attempt >= retryPolicy.MaxAttempts || !IsRetryableStatus(res.StatusCode) {
+					return nil, nil, err
+				}
+				retryAfter = GetRetryAfter(res)
+			} else {
+				return res, content_raw, nil
+			}
+		}
+
+		delay := retryPolicy.Delay(attempt, retryAfter)
+		slog.Warn("Request failed, retrying", "url", request.URL.Path, "attempt", attempt, "delay", delay, "error", err)
+		time.Sleep(delay)
+	}
+}
+
 // Authorizes the given user with the provided password against the given Jellyfin hostname
 // When successfull, an auth token wich can be used for further requests is returned.
 func Authorize(baseUrl string, username string, password string) (*AuthResponse, error) {
@@ -118,6 +157,9 @@ func MakeRequest(token string, requestUrl string, method string, body any) (map[
 	reqbody_json, err := json.Marshal(body)
 
 	req, err := http.NewRequest(method, requestUrl, bytes.NewBuffer(reqbody_json))
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Content-Type", "application/json")
 
 	// Fix Header by inserting the Authorization header with artificial Values
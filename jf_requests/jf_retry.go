@@ -0,0 +1,186 @@
+package jf_requests
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Describes how often and how long to wait before failed requests are repeated.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one.
+	MaxAttempts int
+	// Delay before the first retry. Doubled for each further retry.
+	BaseDelay time.Duration
+	// Upper bound for the computed backoff delay.
+	MaxDelay time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   1 * time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+var retryPolicy = DefaultRetryPolicy
+
+// Time to wait for the headers of a response and between two reads of its body, before a
+// stalled connection is given up.
+const (
+	responseHeaderTimeout = 60 * time.Second
+	readTimeout           = 60 * time.Second
+)
+
+// Returned if a response did not deliver any data within the read timeout. It is a timeout
+// error, so the request is retried.
+var errStalled = fmt.Errorf("no data received for %s: %w", readTimeout, os.ErrDeadlineExceeded)
+
+// Client for all requests. Unlike http.DefaultClient, it gives up on stalled connections
+// instead of waiting forever, so that they are retried.
+var httpClient = &http.Client{Transport: newTransport()}
+
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+	return transport
+}
+
+// Aborts a request if it does not deliver any data for readTimeout. The deadline is reset by
+// every read, so slow but steady downloads are not affected.
+type idleTimeout struct {
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	stalled atomic.Bool
+}
+
+type idleTimeoutReader struct {
+	reader  io.Reader
+	timeout *idleTimeout
+}
+
+// Attaches an idle timeout to the given request. Stop has to be called once the response
+// was read.
+func withIdleTimeout(request *http.Request) (*http.Request, *idleTimeout) {
+	ctx, cancel := context.WithCancel(request.Context())
+	timeout := &idleTimeout{cancel: cancel}
+	timeout.timer = time.AfterFunc(readTimeout, func() {
+		timeout.stalled.Store(true)
+		cancel()
+	})
+
+	return request.WithContext(ctx), timeout
+}
+
+// Returns errStalled, if the request was aborted by the timeout, and err otherwise.
+func (timeout *idleTimeout) check(err error) error {
+	if err != nil && timeout.stalled.Load() {
+		return errStalled
+	}
+
+	return err
+}
+
+// Wraps the body of the response, so that every read resets the timeout.
+func (timeout *idleTimeout) Reader(reader io.Reader) io.Reader {
+	return &idleTimeoutReader{reader: reader, timeout: timeout}
+}
+
+func (timeout *idleTimeout) Stop() {
+	timeout.timer.Stop()
+	timeout.cancel()
+}
+
+func (reader *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	if n > 0 {
+		reader.timeout.timer.Reset(readTimeout)
+	}
+
+	return n, reader.timeout.check(err)
+}
+
+// Sets the retry policy which is used for all API requests and downloads.
+func SetRetryPolicy(policy RetryPolicy) {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	retryPolicy = policy
+}
+
+// Returns the time to wait before the given retry (starting at 1). If the server sent a
+// Retry-After value, it is used instead of the computed exponential backoff.
+func (policy RetryPolicy) Delay(retry int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	delay := policy.BaseDelay << (retry - 1)
+	if delay <= 0 || delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	// Add jitter so that parallel downloads do not hit the server at the same time again.
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// Returns true, if a request which returned the given status code should be repeated.
+func IsRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// Returns true, if the given error is caused by a transient network problem.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Parses the Retry-After header of the given response. Both, the delay in seconds and the
+// HTTP date format are supported. Returns 0 if the header is missing or invalid.
+func GetRetryAfter(res *http.Response) time.Duration {
+	if res == nil {
+		return 0
+	}
+
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
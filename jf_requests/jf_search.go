@@ -0,0 +1,361 @@
+package jf_requests
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// How well an item matches a search term. Higher values are better matches.
+type matchRank int
+
+const (
+	noMatch matchRank = iota
+	fuzzyMatch
+	tokenMatch
+	prefixMatch
+	exactMatch
+)
+
+// Replacements for characters with diacritics, so that e.g. "Amelie" finds "Amélie".
+var foldedRunes = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ł': "l", 'ľ': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// Normalizes the given text for comparison: lower case, without diacritics and with
+// punctuation replaced by single spaces.
+func foldText(text string) string {
+	var folded strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if replacement, ok := foldedRunes[r]; ok {
+			folded.WriteString(replacement)
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			folded.WriteRune(r)
+		} else {
+			folded.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(folded.String()), " ")
+}
+
+// Returns the number of single character edits needed to turn a into b.
+func levenshtein(a string, b string) int {
+	source, target := []rune(a), []rune(b)
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for idx := range previous {
+		previous[idx] = idx
+	}
+
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(target)]
+}
+
+// Terms shorter than this are not matched fuzzily, since a single typo would match
+// almost every name.
+const minFuzzyLength = 4
+
+// Ranks how well the name matches the search term. Both have to be folded already. The
+// returned distance orders fuzzy matches, it is 0 for all other matches.
+func rankMatch(name string, term string) (matchRank, int) {
+	if term == "" {
+		return noMatch, 0
+	} else if name == term {
+		return exactMatch, 0
+	} else if strings.HasPrefix(name, term) {
+		return prefixMatch, 0
+	}
+
+	if strings.Contains(name, term) {
+		return tokenMatch, 0
+	}
+
+	nameTokens := strings.Fields(name)
+	termTokens := strings.Fields(term)
+
+	allTokens := true
+	for _, termToken := range termTokens {
+		found := false
+		for _, nameToken := range nameTokens {
+			if strings.HasPrefix(nameToken, termToken) {
+				found = true
+				break
+			}
+		}
+		allTokens = allTokens && found
+	}
+	if allTokens {
+		return tokenMatch, 0
+	} else if len([]rune(term)) < minFuzzyLength {
+		return noMatch, 0
+	}
+
+	// Compare the term against the whole name and every sequence of words of the same
+	// length, so that a typo is also found within longer titles.
+	allowed := max(1, len([]rune(term))/4)
+	distance := levenshtein(name, term)
+	for start := 0; start+len(termTokens) <= len(nameTokens); start++ {
+		window := strings.Join(nameTokens[start:start+len(termTokens)], " ")
+		distance = min(distance, levenshtein(window, term))
+	}
+
+	if distance <= allowed {
+		return fuzzyMatch, distance
+	}
+
+	return noMatch, 0
+}
+
+// Returns the items which match the search term, ordered by relevance: exact matches first,
+// then names starting with the term, names containing all words of the term and finally
+// names which only differ by a few typos.
+func SearchItems(items []Item, searchtext string) []Item {
+	type rankedItem struct {
+		item     Item
+		rank     matchRank
+		distance int
+	}
+
+	term := foldText(searchtext)
+	var ranked []rankedItem
+	for _, item := range items {
+		rank, distance := rankMatch(foldText(item.Name), term)
+		if rank != noMatch {
+			ranked = append(ranked, rankedItem{item, rank, distance})
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].rank != ranked[j].rank {
+			return ranked[i].rank > ranked[j].rank
+		}
+		return ranked[i].distance < ranked[j].distance
+	})
+
+	results := make([]Item, 0, len(ranked))
+	for _, entry := range ranked {
+		results = append(results, entry.item)
+	}
+
+	return results
+}
+
+// Item types which can be passed to -type, mapped to the types used by Jellyfin.
+var searchTypes = map[string]string{
+	"series":  "Series",
+	"movie":   "Movie",
+	"episode": "Episode",
+	"album":   "MusicAlbum",
+}
+
+// Restricts a search to items with the given properties. Empty fields are not filtered.
+type SearchFilter struct {
+	Types   []string
+	Years   []int
+	Genres  []string
+	Studios []string
+	// Name of the library to search in.
+	Library string
+}
+
+func (filter *SearchFilter) IsEmpty() bool {
+	return filter == nil || (len(filter.Types) == 0 && len(filter.Years) == 0 && len(filter.Genres) == 0 &&
+		len(filter.Studios) == 0 && filter.Library == "")
+}
+
+// Parses a comma separated list of item types like "series,movie" into Jellyfin item types.
+func ParseItemTypes(value string) ([]string, error) {
+	var types []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		itemType, ok := searchTypes[name]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("unknown type \"%s\", use series, movie, episode or album", name))
+		}
+		types = append(types, itemType)
+	}
+
+	return types, nil
+}
+
+// The oldest year which is accepted by ParseYears.
+const minYear = 1800
+
+// Parses a comma separated list of years and year ranges, e.g. "1999,2010-2015". Years have
+// to be between 1800 and the next year.
+func ParseYears(value string) ([]int, error) {
+	maxYear := time.Now().Year() + 1
+	var years []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		start, end, isRange := strings.Cut(part, "-")
+		if !isRange {
+			end = start
+		}
+
+		from, errFrom := strconv.Atoi(strings.TrimSpace(start))
+		to, errTo := strconv.Atoi(strings.TrimSpace(end))
+		if errFrom != nil || errTo != nil || from > to {
+			return nil, errors.New(fmt.Sprintf("invalid year \"%s\", use e.g. 2010 or 2010-2015", part))
+		} else if from < minYear || to > maxYear {
+			return nil, errors.New(fmt.Sprintf("invalid year \"%s\", years have to be between %d and %d", part, minYear, maxYear))
+		}
+
+		for year := from; year <= to; year++ {
+			years = append(years, year)
+		}
+	}
+
+	return years, nil
+}
+
+// Returns the query parameters which apply the filter, except for the library.
+func (filter *SearchFilter) getQuery() string {
+	query := url.Values{}
+	if len(filter.Types) > 0 {
+		query.Set("IncludeItemTypes", strings.Join(filter.Types, ","))
+	}
+
+	if len(filter.Years) > 0 {
+		years := make([]string, 0, len(filter.Years))
+		for _, year := range filter.Years {
+			years = append(years, strconv.Itoa(year))
+		}
+		query.Set("Years", strings.Join(years, ","))
+	}
+
+	// Genres and studios are separated by pipes, since their names may contain commas.
+	if len(filter.Genres) > 0 {
+		query.Set("Genres", strings.Join(filter.Genres, "|"))
+	}
+	if len(filter.Studios) > 0 {
+		query.Set("Studios", strings.Join(filter.Studios, "|"))
+	}
+
+	return query.Encode()
+}
+
+// Length of the prefix which is sent to the server, if a search for episodes found nothing.
+const fallbackPrefixLength = 3
+
+// Returns the search term for a second, broader search, if the server did not find anything
+// for the given text. Without a library, a search for episodes would return every episode
+// on the server, so only a prefix of the first word is sent in that case. Otherwise, all
+// filtered items are returned.
+func (filter *SearchFilter) getFallbackTerm(searchtext string) string {
+	if filter.Library != "" || !slices.Contains(filter.Types, "Episode") {
+		return ""
+	}
+
+	words := strings.Fields(foldText(searchtext))
+	if len(words) == 0 {
+		return searchtext
+	}
+
+	prefix := []rune(words[0])
+	return string(prefix[:min(len(prefix), fallbackPrefixLength)])
+}
+
+// Returns the library with the given name. Case and diacritics are ignored.
+func GetLibraryForName(auth *AuthResponse, baseurl string, name string) (*Item, error) {
+	libraries, err := GetRootItems(auth, baseurl)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for idx, library := range libraries {
+		if foldText(library.Name) == foldText(name) {
+			return &libraries[idx], nil
+		}
+		names = append(names, library.Name)
+	}
+
+	return nil, errors.New(fmt.Sprintf("no library named \"%s\" found, available are: %s", name, strings.Join(names, ", ")))
+}
+
+// Returns all items matching the given filter. If item types are given, the libraries are
+// searched recursively, e.g. for episodes. Otherwise only their direct children are returned.
+// Recursive searches are restricted to items matching the search text by the server, if one
+// is given, since they would return the whole library otherwise.
+func GetFilteredItems(auth *AuthResponse, baseurl string, filter *SearchFilter, searchtext string) ([]Item, error) {
+	var parents []Item
+	if filter.Library != "" {
+		library, err := GetLibraryForName(auth, baseurl, filter.Library)
+		if err != nil {
+			return nil, err
+		}
+		parents = []Item{*library}
+	} else if len(filter.Types) == 0 {
+		libraries, err := GetRootItems(auth, baseurl)
+		if err != nil {
+			return nil, err
+		}
+		parents = libraries
+	}
+
+	query := filter.getQuery()
+	if len(filter.Types) > 0 {
+		query += "&Recursive=true"
+		if searchtext != "" {
+			query += "&SearchTerm=" + url.QueryEscape(searchtext)
+		}
+	}
+
+	if len(parents) == 0 {
+		requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items?%s", auth.UserId, query)
+		res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return GetItem(res["Items"].([]any), nil), nil
+	}
+
+	var items []Item
+	for _, parent := range parents {
+		requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items?ParentId=%s&%s", auth.UserId, parent.Id, query)
+		res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, GetItem(res["Items"].([]any), &parent)...)
+	}
+
+	return items, nil
+}
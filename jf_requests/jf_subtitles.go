@@ -0,0 +1,70 @@
+package jf_requests
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+func GetSubtitleLinkForStream(baseUrl string, token string, id string, mediaSourceId string, index int) string {
+	return fmt.Sprintf("%s/Videos/%s/%s/Subtitles/%d/Stream.srt?api_key=%s", baseUrl, id, mediaSourceId, index, token)
+}
+
+// Returns true, if the given subtitle language was requested. "all" matches every language.
+func matchesLanguage(languages []string, language string) bool {
+	return slices.ContainsFunc(languages, func(requested string) bool {
+		return requested == "all" || strings.EqualFold(requested, language)
+	})
+}
+
+// Downloads all subtitles of the given media source whose language matches one of the given
+// languages. The subtitles are stored next to the media file, e.g. "Name.eng.srt" for the
+// media file "Name.mkv".
+func DownloadSubtitles(baseUrl string, token string, id string, source *MediaSource, mediafile string, languages []string) {
+	basename := strings.TrimSuffix(mediafile, "."+GetSuffixFromFilename(mediafile))
+	usedFilenames := make(map[string]bool)
+
+	for _, stream := range source.GetStreamsOfType("Subtitle") {
+		language := stream.Language
+		if language == "" {
+			language = "und"
+		}
+
+		if !matchesLanguage(languages, language) {
+			continue
+		}
+
+		// Image based subtitles like PGS can not be converted into the srt format.
+		if !stream.IsTextSubtitleStream {
+			color.Yellow("Skipping subtitle \"%s\" which is not text based", stream.DisplayTitle)
+			continue
+		}
+
+		suffix := language
+		if stream.IsForced {
+			suffix += ".forced"
+		}
+
+		outfile := fmt.Sprintf("%s.%s.srt", basename, suffix)
+		if usedFilenames[outfile] {
+			outfile = fmt.Sprintf("%s.%s.%d.srt", basename, suffix, stream.Index)
+		}
+		usedFilenames[outfile] = true
+
+		link := GetSubtitleLinkForStream(baseUrl, token, id, source.Id, stream.Index)
+		if err := DownloadFromUrl(link, stream.DisplayTitle, outfile, 1, 0); err != nil {
+			color.Red("Failed to download subtitle \"%s\": %s", stream.DisplayTitle, err)
+		}
+	}
+}
+
+// Downloads the requested subtitles for the given media file, if any were requested.
+func (options *DownloadOptions) downloadSubtitles(baseUrl string, token string, id string, source *MediaSource, mediafile string) {
+	if len(options.SubtitleLanguages) == 0 || source == nil {
+		return
+	}
+
+	DownloadSubtitles(baseUrl, token, id, source, mediafile, options.SubtitleLanguages)
+}
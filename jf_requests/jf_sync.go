@@ -0,0 +1,94 @@
+package jf_requests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// An item which was watched while being offline.
+type WatchedEntry struct {
+	Id string
+	// Optional date at which the item was watched, e.g. "2024-05-01T20:15:00Z".
+	DatePlayed string
+}
+
+// Reads the list of watched items from the given file. Either a JSON list of ids or of
+// objects with an "Id" and an optional "DatePlayed" field, or a plain text file with one id
+// per line are supported. Empty lines and lines starting with # are ignored.
+func ReadWatchedList(filename string) ([]WatchedEntry, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to read watched list: %s", err))
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var raw []any
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, errors.New(fmt.Sprintf("Failed to parse watched list: %s", err))
+		}
+
+		var entries []WatchedEntry
+		for idx, rawEntry := range raw {
+			var entry WatchedEntry
+			switch rawEntry := rawEntry.(type) {
+			case string:
+				entry = WatchedEntry{Id: rawEntry}
+			case map[string]any:
+				entry = WatchedEntry{Id: getString(rawEntry, "Id"), DatePlayed: getString(rawEntry, "DatePlayed")}
+			default:
+				return nil, errors.New(fmt.Sprintf("Unexpected entry in watched list: %v", rawEntry))
+			}
+
+			entry.Id = strings.TrimSpace(entry.Id)
+			if entry.Id == "" {
+				return nil, errors.New(fmt.Sprintf("Entry %d of the watched list has no \"Id\"", idx+1))
+			}
+			entries = append(entries, entry)
+		}
+
+		return entries, nil
+	}
+
+	var entries []WatchedEntry
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entries = append(entries, WatchedEntry{Id: line})
+	}
+
+	return entries, nil
+}
+
+// Returns the item with the given id together with the playback state of the user.
+func GetItemWithUserData(auth *AuthResponse, baseurl string, id string) (*Item, *UserData, error) {
+	requestUrl := baseurl + fmt.Sprintf("/Users/%s/Items/%s", auth.UserId, url.PathEscape(id))
+	res, err := MakeRequest(auth.Token, requestUrl, "GET", nil)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("Failed to find item with id: %s - %s", id, err))
+	}
+
+	userData := GetUserData(res)
+	return &GetItem([]any{res}, nil)[0], &userData, nil
+}
+
+// Marks the given item as played for the logged in user.
+func MarkAsPlayed(auth *AuthResponse, baseurl string, entry WatchedEntry) error {
+	requestUrl := baseurl + fmt.Sprintf("/Users/%s/PlayedItems/%s", auth.UserId, url.PathEscape(entry.Id))
+	if entry.DatePlayed != "" {
+		requestUrl += "?datePlayed=" + url.QueryEscape(entry.DatePlayed)
+	}
+
+	_, err := MakeRequest(auth.Token, requestUrl, "POST", nil)
+	return err
+}
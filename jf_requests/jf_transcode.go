@@ -0,0 +1,58 @@
+package jf_requests
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Parameters which are passed to the Jellyfin server when an item should be transcoded
+// instead of downloading the original file.
+type TranscodeOptions struct {
+	// Target container, e.g. mp4 or mkv. Also used as suffix of the resulting file.
+	Container  string
+	VideoCodec string
+	AudioCodec string
+	// Maximum video bitrate in kbit/s. 0 lets the server decide.
+	MaxBitrate int
+	// Maximum height of the video, e.g. 720. 0 keeps the original resolution.
+	MaxHeight int
+	// Index of the audio stream which should be used. -1 uses the default stream.
+	AudioStreamIndex int
+	// Index of the subtitle stream which should be burned in. -1 disables subtitles.
+	SubtitleStreamIndex int
+}
+
+// Returns the link to the transcoded stream of the item with the given id. If a media
+// source id is given, that version of the item is transcoded.
+func (options *TranscodeOptions) GetStreamLinkForId(baseUrl string, token string, id string, mediaSourceId string) string {
+	query := url.Values{}
+	query.Set("api_key", token)
+	query.Set("Static", "false")
+
+	if mediaSourceId != "" {
+		query.Set("MediaSourceId", mediaSourceId)
+	}
+
+	if options.VideoCodec != "" {
+		query.Set("VideoCodec", options.VideoCodec)
+	}
+	if options.AudioCodec != "" {
+		query.Set("AudioCodec", options.AudioCodec)
+	}
+	if options.MaxBitrate > 0 {
+		query.Set("VideoBitrate", strconv.Itoa(options.MaxBitrate*1000))
+	}
+	if options.MaxHeight > 0 {
+		query.Set("MaxHeight", strconv.Itoa(options.MaxHeight))
+	}
+	if options.AudioStreamIndex >= 0 {
+		query.Set("AudioStreamIndex", strconv.Itoa(options.AudioStreamIndex))
+	}
+	if options.SubtitleStreamIndex >= 0 {
+		query.Set("SubtitleStreamIndex", strconv.Itoa(options.SubtitleStreamIndex))
+		query.Set("SubtitleMethod", "Encode")
+	}
+
+	return fmt.Sprintf("%s/Videos/%s/stream.%s?%s", baseUrl, id, options.Container, query.Encode())
+}
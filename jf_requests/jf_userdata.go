@@ -0,0 +1,100 @@
+package jf_requests
+
+// Playback state of an item for the logged in user.
+type UserData struct {
+	Played                bool
+	IsFavorite            bool
+	PlaybackPositionTicks int64
+}
+
+func GetUserData(rawItem map[string]any) UserData {
+	raw, _ := rawItem["UserData"].(map[string]any)
+
+	return UserData{
+		Played:                getBool(raw, "Played"),
+		IsFavorite:            getBool(raw, "IsFavorite"),
+		PlaybackPositionTicks: getInt64(raw, "PlaybackPositionTicks"),
+	}
+}
+
+// Returns true, if the item was started but not watched until the end.
+func (userData *UserData) InProgress() bool {
+	return !userData.Played && userData.PlaybackPositionTicks > 0
+}
+
+// Restricts the episodes which are downloaded based on the playback state.
+type EpisodeFilter struct {
+	Unwatched  bool
+	Favorites  bool
+	InProgress bool
+	// If greater than 0, only the given number of episodes following the last watched
+	// episode are kept.
+	NextUp int
+}
+
+// Returns true, if no restriction is set.
+func (filter *EpisodeFilter) IsEmpty() bool {
+	return !filter.Unwatched && !filter.Favorites && !filter.InProgress && filter.NextUp <= 0
+}
+
+func (filter *EpisodeFilter) matches(episode *Episode) bool {
+	if filter.Unwatched && episode.UserData.Played {
+		return false
+	}
+	if filter.Favorites && !episode.UserData.IsFavorite {
+		return false
+	}
+	if filter.InProgress && !episode.UserData.InProgress() {
+		return false
+	}
+
+	return true
+}
+
+// Removes all episodes which do not match the filter. Seasons without any remaining
+// episodes are removed as well.
+func (series *Series) ApplyFilter(filter *EpisodeFilter) {
+	if filter == nil || filter.IsEmpty() {
+		return
+	}
+
+	// Next up starts after the last watched episode of the whole series.
+	lastPlayedSeason, lastPlayedEpisode := -1, -1
+	if filter.NextUp > 0 {
+		for seasonIdx, season := range series.Seasons {
+			for idx, episode := range season.Episodes {
+				if episode.UserData.Played {
+					lastPlayedSeason, lastPlayedEpisode = seasonIdx, idx
+				}
+			}
+		}
+	}
+
+	remaining := filter.NextUp
+	var seasons []Season
+	for seasonIdx, season := range series.Seasons {
+		var episodes []Episode
+		for idx, episode := range season.Episodes {
+			if !filter.matches(&episode) {
+				continue
+			}
+
+			if filter.NextUp > 0 {
+				afterLastPlayed := seasonIdx > lastPlayedSeason || (seasonIdx == lastPlayedSeason && idx > lastPlayedEpisode)
+				if !afterLastPlayed || episode.UserData.Played || remaining == 0 {
+					continue
+				}
+				remaining--
+			}
+
+			episodes = append(episodes, episode)
+		}
+
+		if len(episodes) > 0 {
+			season.Episodes = episodes
+			seasons = append(seasons, season)
+		}
+	}
+
+	series.Seasons = seasons
+}
@@ -0,0 +1,342 @@
+package jf_requests
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Name of the file in the output directory which stores the checksums of all verified
+// downloads. It uses the format of sha256sum, so it can be checked with "sha256sum -c" too.
+const ChecksumFilename = "checksums.sha256"
+
+// Returned if a downloaded file is truncated or does not contain the expected media.
+var ErrVerificationFailed = errors.New("verification failed")
+
+func verificationError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrVerificationFailed, fmt.Sprintf(format, args...))
+}
+
+// Checks that the file has the expected size, if known, and that its content looks like
+// the media container given by its suffix.
+func VerifyFile(path string, expectedSize int64) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if expectedSize > 0 && info.Size() != expectedSize {
+		return verificationError("expected %d bytes, but the file has %d bytes", expectedSize, info.Size())
+	}
+
+	return ProbeContainer(path)
+}
+
+// Checks the header and the basic structure of MKV and MP4 files, so that truncated files
+// and error pages which were stored instead of the media are detected. Other formats are
+// only checked for error pages.
+func ProbeContainer(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(file, header)
+	header = header[:n]
+
+	if n == 0 {
+		return verificationError("the file is empty")
+	}
+
+	start := bytes.ToLower(bytes.TrimSpace(header))
+	for _, prefix := range []string{"<!doctype", "<html", "<?xml", "{"} {
+		if bytes.HasPrefix(start, []byte(prefix)) {
+			return verificationError("the file contains a text response instead of media")
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mkv", ".mka", ".webm":
+		return probeMatroska(file, header, info.Size())
+	case ".mp4", ".m4v", ".m4a", ".mov":
+		return probeMp4(file, info.Size())
+	}
+
+	return nil
+}
+
+// Reads a variable length integer as used by EBML. The length marker is kept for element
+// ids and removed for sizes. Returns the value, the number of bytes used and whether the
+// value has the reserved meaning "unknown".
+func readEbmlInt(data []byte, keepMarker bool) (uint64, int, bool) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, false
+	}
+
+	length := 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+
+	if len(data) < length {
+		return 0, 0, false
+	}
+
+	value := uint64(data[0])
+	if !keepMarker {
+		value &= uint64(0xff >> length)
+	}
+
+	allOnes := value == uint64(0xff>>length)
+	for _, b := range data[1:length] {
+		value = value<<8 | uint64(b)
+		allOnes = allOnes && b == 0xff
+	}
+
+	return value, length, allOnes && !keepMarker
+}
+
+// Matroska files start with an EBML header, followed by a single segment which contains
+// the media. The size of the segment tells whether the file is complete.
+func probeMatroska(file *os.File, header []byte, fileSize int64) error {
+	if !bytes.HasPrefix(header, []byte{0x1a, 0x45, 0xdf, 0xa3}) {
+		return verificationError("missing Matroska header")
+	}
+
+	offset := 4
+	headerSize, length, _ := readEbmlInt(header[offset:], false)
+	if length == 0 || headerSize > uint64(len(header)) {
+		return verificationError("invalid Matroska header")
+	}
+	offset += length + int(headerSize)
+
+	if offset >= len(header) {
+		return verificationError("invalid Matroska header")
+	}
+
+	id, length, _ := readEbmlInt(header[offset:], true)
+	if id != 0x18538067 {
+		return verificationError("missing Matroska segment")
+	}
+	offset += length
+
+	segmentSize, length, unknown := readEbmlInt(header[offset:], false)
+	if length == 0 {
+		return verificationError("invalid Matroska segment")
+	}
+	offset += length
+
+	// Live streams and some muxers do not write the size of the segment.
+	if !unknown && int64(offset)+int64(segmentSize) > fileSize {
+		return verificationError("the file is truncated, %d of %d bytes are missing", int64(offset)+int64(segmentSize)-fileSize, int64(offset)+int64(segmentSize))
+	}
+
+	return nil
+}
+
+// MP4 files consist of a sequence of boxes, which have to fill the file exactly. The
+// "moov" box with the index of the media is required for playback.
+func probeMp4(file *os.File, fileSize int64) error {
+	var offset int64
+	foundMoov := false
+	boxHeader := make([]byte, 16)
+
+	for offset < fileSize {
+		if _, err := file.ReadAt(boxHeader[:8], offset); err != nil {
+			return verificationError("the file is truncated within a box header")
+		}
+
+		size := int64(binary.BigEndian.Uint32(boxHeader[:4]))
+		boxType := string(boxHeader[4:8])
+		headerSize := int64(8)
+
+		for _, c := range boxType {
+			if c < 0x20 || c > 0x7e {
+				return verificationError("invalid MP4 box at offset %d", offset)
+			}
+		}
+
+		switch size {
+		case 0:
+			// The box extends to the end of the file.
+			size = fileSize - offset
+		case 1:
+			if _, err := file.ReadAt(boxHeader[8:16], offset+8); err != nil {
+				return verificationError("the file is truncated within a box header")
+			}
+			size = int64(binary.BigEndian.Uint64(boxHeader[8:16]))
+			headerSize = 16
+		}
+
+		if size < headerSize {
+			return verificationError("invalid size of MP4 box \"%s\"", boxType)
+		} else if offset+size > fileSize {
+			return verificationError("the file is truncated, %d bytes of box \"%s\" are missing", offset+size-fileSize, boxType)
+		}
+
+		if boxType == "moov" {
+			foundMoov = true
+		}
+		offset += size
+	}
+
+	if !foundMoov {
+		return verificationError("missing MP4 index (moov box)")
+	}
+
+	return nil
+}
+
+// Returns the hex encoded SHA-256 checksum of the given file.
+func GetSha256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Checksums of the downloaded files, stored relative to the directory of the manifest.
+type ChecksumManifest struct {
+	Directory string
+	Checksums map[string]string
+}
+
+// Reads the checksum manifest of the given directory. If it does not exist yet, an empty
+// manifest is returned. Lines in text ("hash  path") and binary ("hash *path") format of
+// sha256sum are accepted.
+func ReadChecksumManifest(directory string) (*ChecksumManifest, error) {
+	if directory == "" {
+		directory = "."
+	}
+
+	manifest := &ChecksumManifest{Directory: directory, Checksums: make(map[string]string)}
+
+	file, err := os.Open(filepath.Join(directory, ChecksumFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to read checksums: %s", err))
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		checksum, rest, found := strings.Cut(scanner.Text(), " ")
+		if found && (strings.HasPrefix(rest, " ") || strings.HasPrefix(rest, "*")) && len(rest) > 1 {
+			manifest.Checksums[filepath.FromSlash(rest[1:])] = checksum
+		}
+	}
+
+	return manifest, scanner.Err()
+}
+
+func (manifest *ChecksumManifest) getRelativePath(path string) string {
+	relative, err := filepath.Rel(manifest.Directory, path)
+	if err != nil {
+		return path
+	}
+
+	return relative
+}
+
+// Adds the checksum of the given file and saves the manifest.
+func (manifest *ChecksumManifest) Add(path string, checksum string) error {
+	manifest.Checksums[manifest.getRelativePath(path)] = checksum
+	return manifest.save()
+}
+
+// Returns the recorded checksum of the given file or an empty string, if there is none.
+func (manifest *ChecksumManifest) Get(path string) string {
+	if manifest == nil {
+		return ""
+	}
+
+	return manifest.Checksums[manifest.getRelativePath(path)]
+}
+
+// Returns the paths of all files in the manifest, relative to its directory.
+func (manifest *ChecksumManifest) GetFiles() []string {
+	files := make([]string, 0, len(manifest.Checksums))
+	for file := range manifest.Checksums {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+func (manifest *ChecksumManifest) save() error {
+	var content strings.Builder
+	for _, file := range manifest.GetFiles() {
+		fmt.Fprintf(&content, "%s  %s\n", manifest.Checksums[file], filepath.ToSlash(file))
+	}
+
+	if err := os.MkdirAll(manifest.Directory, 0755); err != nil {
+		return errors.New(fmt.Sprintf("Failed to create directory: %s", err))
+	}
+
+	// Write into a temporary file first, so an interrupted run does not lose the manifest.
+	outfile := filepath.Join(manifest.Directory, ChecksumFilename)
+	if err := os.WriteFile(outfile+".tmp", []byte(content.String()), 0644); err != nil {
+		return errors.New(fmt.Sprintf("Failed to write checksums: %s", err))
+	}
+
+	return os.Rename(outfile+".tmp", outfile)
+}
+
+// Verifies a finished download. Files which fail the verification are moved aside to a
+// ".corrupt" file for inspection, so they are downloaded again by the next run.
+func (options *DownloadOptions) verifyDownload(outfile string, expectedSize int64) error {
+	err := VerifyFile(outfile, expectedSize)
+	if err == nil {
+		return nil
+	}
+
+	corrupt := outfile + ".corrupt"
+	if renameErr := os.Rename(outfile, corrupt); renameErr != nil {
+		return fmt.Errorf("%w, failed to move the file aside: %s", err, renameErr)
+	}
+
+	return fmt.Errorf("%w, the file was moved to \"%s\"", err, corrupt)
+}
+
+// Calculates the checksum of a finished download and records it in the checksum manifest,
+// if there is one.
+func (options *DownloadOptions) recordChecksum(outfile string) (string, error) {
+	checksum, err := GetSha256(outfile)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Failed to calculate checksum: %s", err))
+	}
+
+	if options.Checksums != nil {
+		if err := options.Checksums.Add(outfile, checksum); err != nil {
+			return checksum, err
+		}
+	}
+
+	return checksum, nil
+}
@@ -0,0 +1,130 @@
+package jf_requests
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Returns the first video stream of the media source or nil, if there is none.
+func (source *MediaSource) GetVideoStream() *MediaStream {
+	for idx := range source.MediaStreams {
+		if source.MediaStreams[idx].Type == "Video" {
+			return &source.MediaStreams[idx]
+		}
+	}
+
+	return nil
+}
+
+// Returns a resolution label like "1080p" for the media source. The width is taken into
+// account as well, since widescreen movies often have a lower height than their label suggests.
+func (source *MediaSource) GetResolutionLabel() string {
+	video := source.GetVideoStream()
+	if video == nil || video.Height == 0 {
+		return ""
+	}
+
+	switch {
+	case video.Width >= 3800 || video.Height >= 2100:
+		return "2160p"
+	case video.Width >= 2500 || video.Height >= 1400:
+		return "1440p"
+	case video.Width >= 1900 || video.Height >= 1000:
+		return "1080p"
+	case video.Width >= 1260 || video.Height >= 700:
+		return "720p"
+	default:
+		return fmt.Sprintf("%dp", video.Height)
+	}
+}
+
+// Returns the filename of the media source, which may differ between different versions.
+func (source *MediaSource) GetFilename() string {
+	if source.Path == "" {
+		return ""
+	}
+
+	return path.Base(source.Path)
+}
+
+// Returns a short human readable description of the media source, e.g. "1080p hevc mkv 4.2 GiB".
+func (source *MediaSource) Describe() string {
+	var parts []string
+	if source.Name != "" {
+		parts = append(parts, source.Name)
+	}
+	if resolution := source.GetResolutionLabel(); resolution != "" {
+		parts = append(parts, resolution)
+	}
+	if video := source.GetVideoStream(); video != nil && video.Codec != "" {
+		parts = append(parts, video.Codec)
+	}
+	if source.Container != "" {
+		parts = append(parts, source.Container)
+	}
+	if source.Size > 0 {
+		parts = append(parts, FormatSize(source.Size))
+	}
+
+	return strings.Join(parts, " - ")
+}
+
+// Selects a media source based on the given filter. Supported filters are "smallest",
+// "largest" and resolution labels like "1080p". An empty filter selects the default source.
+// Returns nil, if no media sources are known.
+func SelectMediaSource(sources []MediaSource, filter string) (*MediaSource, error) {
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	selected := &sources[0]
+
+	switch filter {
+	case "":
+	case "smallest", "largest":
+		for idx := range sources {
+			if (filter == "smallest" && sources[idx].Size < selected.Size) ||
+				(filter == "largest" && sources[idx].Size > selected.Size) {
+				selected = &sources[idx]
+			}
+		}
+	default:
+		selected = nil
+		for idx := range sources {
+			if strings.ToLower(sources[idx].GetResolutionLabel()) == filter {
+				selected = &sources[idx]
+				break
+			}
+		}
+
+		if selected == nil {
+			return &sources[0], errors.New(fmt.Sprintf("no version matches the filter \"%s\"", filter))
+		}
+	}
+
+	return selected, nil
+}
+
+// Lists all given media sources and lets the user choose one of them.
+func PrintAndGetVersionSelection(sources []MediaSource) (*MediaSource, error) {
+	fmt.Println("Multiple versions are available. Please select the version you want to download:")
+
+	for idx, source := range sources {
+		color.Cyan("  %d. %s", idx+1, source.Describe())
+	}
+
+	choice, err := GetUserChoice(len(sources))
+	if err != nil {
+		return nil, err
+	}
+	if choice == 0 {
+		return nil, errors.New("invalid selection")
+	}
+
+	return &sources[choice-1], nil
+}
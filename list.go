@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"jf_requests/jf_requests"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+)
+
+// A single row of the library listing.
+type listEntry struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	SeriesName string `json:"seriesName,omitempty"`
+	Type       string `json:"type"`
+	Year       int    `json:"year,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	Container  string `json:"container,omitempty"`
+	Codec      string `json:"codec,omitempty"`
+	// Not set for folders, which are never downloadable themselves.
+	CanDownload *bool `json:"canDownload,omitempty"`
+}
+
+var listColumns = []string{"id", "name", "seriesName", "type", "year", "size", "container", "codec", "canDownload"}
+
+func newListEntry(item *jf_requests.Item) listEntry {
+	var canDownload *bool
+	if !item.IsFolder {
+		canDownload = &item.CanDownload
+	}
+
+	return listEntry{
+		Id:          item.Id,
+		Name:        item.Name,
+		SeriesName:  item.SeriesName,
+		Type:        item.Type,
+		Year:        item.ProductionYear,
+		Size:        item.Size,
+		Container:   item.Container,
+		Codec:       item.Codec,
+		CanDownload: canDownload,
+	}
+}
+
+func (entry *listEntry) columns() []string {
+	year, canDownload := "", ""
+	if entry.Year != 0 {
+		year = strconv.Itoa(entry.Year)
+	}
+	if entry.CanDownload != nil {
+		canDownload = strconv.FormatBool(*entry.CanDownload)
+	}
+
+	return []string{entry.Id, entry.Name, entry.SeriesName, entry.Type, year, strconv.FormatInt(entry.Size, 10),
+		entry.Container, entry.Codec, canDownload}
+}
+
+func writeListText(out io.Writer, entries []listEntry) error {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TYPE\tYEAR\tSIZE\tCONTAINER\tCODEC\tDOWNLOAD\tNAME\tID")
+
+	for _, entry := range entries {
+		year, size, download := "", "", "-"
+		if entry.Year != 0 {
+			year = strconv.Itoa(entry.Year)
+		}
+		if entry.Size > 0 {
+			size = jf_requests.FormatSize(entry.Size)
+		}
+		if entry.CanDownload != nil && *entry.CanDownload {
+			download = "yes"
+		} else if entry.CanDownload != nil {
+			download = "no"
+		}
+
+		name := entry.Name
+		if entry.SeriesName != "" && entry.Type != "Series" {
+			name = fmt.Sprintf("%s - %s", entry.SeriesName, entry.Name)
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Type, year, size, entry.Container, entry.Codec, download, name, entry.Id)
+	}
+
+	return writer.Flush()
+}
+
+func writeListCsv(out io.Writer, entries []listEntry) error {
+	writer := csv.NewWriter(out)
+	writer.Write(listColumns)
+	for _, entry := range entries {
+		writer.Write(entry.columns())
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeListJson(out io.Writer, entries []listEntry) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// Returns the format of the listing. Without -format, it is derived from the suffix of the
+// export file and defaults to a human readable table.
+func getListFormat(args *Arguments) string {
+	if args.Format != "" {
+		return strings.ToLower(args.Format)
+	}
+
+	switch strings.ToLower(filepath.Ext(args.Export)) {
+	case ".csv":
+		return "csv"
+	case ".json":
+		return "json"
+	}
+
+	return "text"
+}
+
+// Lists the content of all libraries with the details needed to plan downloads, either as
+// table or exported as CSV or JSON.
+func ListLibraries(args *Arguments, auth *jf_requests.AuthResponse) bool {
+	filter, _ := GetSearchFilter(args)
+	items, err := jf_requests.GetLibraryItems(auth, args.BaseUrl, filter)
+	if err != nil {
+		color.Red("Failed to list the libraries: %s", err)
+		return false
+	}
+
+	entries := make([]listEntry, 0, len(items))
+	var totalSize int64
+	var notDownloadable int
+	for idx := range items {
+		entries = append(entries, newListEntry(&items[idx]))
+		totalSize += items[idx].Size
+		if !items[idx].IsFolder && !items[idx].CanDownload {
+			notDownloadable++
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if args.Export != "" {
+		file, err := os.Create(args.Export)
+		if err != nil {
+			color.Red("Failed to create export file: %s", err)
+			return false
+		}
+		defer file.Close()
+		out = file
+	}
+
+	format := getListFormat(args)
+	switch format {
+	case "csv":
+		err = writeListCsv(out, entries)
+	case "json":
+		err = writeListJson(out, entries)
+	default:
+		err = writeListText(out, entries)
+	}
+
+	if err != nil {
+		color.Red("Failed to write the listing: %s", err)
+		return false
+	}
+
+	// Keep stdout machine readable when exporting to it.
+	if args.Export != "" || format == "text" {
+		if args.Export != "" {
+			color.Green("Exported %d items to %s", len(entries), args.Export)
+		}
+		fmt.Printf("%d items, %s in total, %d not downloadable\n", len(entries), jf_requests.FormatSize(totalSize), notDownloadable)
+	}
+
+	return true
+}
@@ -21,13 +21,48 @@ import (
 const VERSION string = "v1.4.0"
 
 type Arguments struct {
+	Command       string
 	BaseUrl       string
 	Username      string
 	Password      string
 	SeriesId      string
 	SeasonId      string
 	Name          string
+	Playlist      string
+	ItemType      string
+	Year          string
+	Genre         string
+	Studio        string
+	Library       string
+	Format        string
+	Export        string
 	KeepFilenames bool
+	Output        string
+	Retries       int
+	RetryDelay    time.Duration
+	LimitRate     string
+	LimitSchedule string
+	MinFree       string
+	Transcode     bool
+	Container     string
+	VideoCodec    string
+	AudioCodec    string
+	MaxBitrate    int
+	MaxHeight     int
+	AudioStream   int
+	SubStream     int
+	Subs          string
+	VersionFilter string
+	Nfo           bool
+	Images        bool
+	Verify        bool
+	Manifest      bool
+	Unwatched     bool
+	Favorites     bool
+	InProgress    bool
+	NextUp        int
+	WatchedFile   string
+	DryRun        bool
 	Version       bool
 	Debug         bool
 }
@@ -37,22 +72,73 @@ func ParseCLIArgs() *Arguments {
 	var args = Arguments{}
 
 	flag.StringVar(&args.BaseUrl, "url", "", "Base URL which points to the Jellyfin Instance")
-	flag.StringVar(&args.SeriesId, "seriesid", "", "ID which points to the series which should be downloaded")
+	flag.StringVar(&args.SeriesId, "seriesid", "", "ID which points to the series, season, episode or movie which should be downloaded")
 	flag.StringVar(&args.SeasonId, "seasonid", "", "If given, only the episodes with the provided season Id will be downloaded")
 	flag.StringVar(&args.Username, "username", "", "Username used to login to the Jellyfin instance. If not provided, password will be prompted.")
 	flag.StringVar(&args.Password, "password", "", "Passwort for the Jellyfin instance. If not provided, username will be prompted.")
 	flag.StringVar(&args.Name, "name", "", "Name of the Show or Movie you want to download.")
+	flag.StringVar(&args.ItemType, "type", "", "Only search for or list items of the given types, comma separated: series, movie, episode or album.")
+	flag.StringVar(&args.Year, "year", "", "Only search for or list items released in the given years, e.g. 2010 or 2010-2015.")
+	flag.StringVar(&args.Genre, "genre", "", "Only search for or list items of the given genres, comma separated.")
+	flag.StringVar(&args.Studio, "studio", "", "Only search for or list items of the given studios, comma separated.")
+	flag.StringVar(&args.Library, "library", "", "Only search or list within the library with the given name. The mirror command accepts a comma separated list.")
+	flag.StringVar(&args.Format, "format", "", "Output format of the list command: text, csv or json. Derived from the -export suffix if not given.")
+	flag.StringVar(&args.Export, "export", "", "File the list command writes to instead of printing the listing.")
+	flag.StringVar(&args.Playlist, "playlist", "", "ID or Name of a playlist whose entries should be downloaded.")
 	flag.BoolVar(&args.KeepFilenames, "keepFilenames", false, "Keeps the original filenames.")
+	flag.StringVar(&args.Output, "output", "", "Directory in which the downloaded files are stored. Defaults to the working directory.")
+	flag.IntVar(&args.Retries, "retries", jf_requests.DefaultRetryPolicy.MaxAttempts-1, "Number of times a failed request or download is retried before giving up.")
+	flag.DurationVar(&args.RetryDelay, "retryDelay", jf_requests.DefaultRetryPolicy.BaseDelay, "Initial delay between retries. The delay doubles with every further retry.")
+	flag.StringVar(&args.LimitRate, "limit-rate", "", "Limits the download speed over all downloads, e.g. 500K or 5M (bytes per second).")
+	flag.StringVar(&args.LimitSchedule, "limit-schedule", "", "Only apply -limit-rate during the given time of day, e.g. 08:00-23:00. Outside of it, downloads run at full speed.")
+	flag.StringVar(&args.MinFree, "min-free", "", "Space which has to remain free on the target disk, e.g. 10G. Downloads are aborted below it and can be resumed later.")
+	flag.BoolVar(&args.Transcode, "transcode", false, "Let the server transcode the media instead of downloading the original files.")
+	flag.StringVar(&args.Container, "container", "mp4", "Target container of transcoded files. Also used as file suffix.")
+	flag.StringVar(&args.VideoCodec, "videoCodec", "h264", "Target video codec of transcoded files.")
+	flag.StringVar(&args.AudioCodec, "audioCodec", "aac", "Target audio codec of transcoded files.")
+	flag.IntVar(&args.MaxBitrate, "maxBitrate", 0, "Maximum video bitrate of transcoded files in kbit/s.")
+	flag.IntVar(&args.MaxHeight, "maxHeight", 0, "Maximum vertical resolution of transcoded files, e.g. 720.")
+	flag.IntVar(&args.AudioStream, "audioStream", -1, "Index of the audio stream which should be used for transcoded files.")
+	flag.IntVar(&args.SubStream, "subtitleStream", -1, "Index of the subtitle stream which should be burned into transcoded files.")
+	flag.StringVar(&args.Subs, "subs", "", "Comma separated list of subtitle languages which are downloaded next to the media, e.g. eng,ger. Use \"all\" to get every subtitle.")
+	flag.StringVar(&args.VersionFilter, "version-filter", "", "Version to download for items with multiple versions: smallest, largest or a resolution like 1080p. If not given, you will be asked for movies, episodes use the default version.")
+	flag.BoolVar(&args.Nfo, "nfo", false, "Write Kodi compatible .nfo metadata files next to the downloaded media.")
+	flag.BoolVar(&args.Images, "images", false, "Download posters, backdrops, logos and thumbnails next to the downloaded media.")
+	flag.BoolVar(&args.Verify, "verify", false, "Verify the size and structure of downloaded media files and record their SHA-256 checksums in the output directory.")
+	flag.BoolVar(&args.Manifest, "manifest", false, "Write a JSON manifest of all attempted downloads with their path, size, speed, checksum (with -verify) and status into the output directory.")
+	flag.BoolVar(&args.Unwatched, "unwatched", false, "Only download episodes which were not watched yet.")
+	flag.BoolVar(&args.Favorites, "favorites", false, "Only download episodes which are marked as favorite.")
+	flag.BoolVar(&args.InProgress, "in-progress", false, "Only download episodes which were started but not finished.")
+	flag.IntVar(&args.NextUp, "next-up", 0, "Only download the given number of episodes following the last watched episode.")
+	flag.StringVar(&args.WatchedFile, "watched", "", "File with the ids of items watched offline (one id per line or a JSON list), used by sync-watched.")
+	flag.BoolVar(&args.DryRun, "dry-run", false, "Only report what sync-watched would change on the server.")
 	flag.BoolVar(&args.Version, "version", false, "Shows the Version Informations and Exit")
 	flag.BoolVar(&args.Debug, "debug", false, "Show verbose debug output which may be useful to find certain problems")
 
-	flag.Parse()
+	flag.Usage = PrintUsage
+
+	// The first argument may name a command, e.g. "nextup". Without it, items are downloaded.
+	args.Command = "download"
+	cliArgs := os.Args[1:]
+	if len(cliArgs) > 0 && !strings.HasPrefix(cliArgs[0], "-") {
+		args.Command = cliArgs[0]
+		cliArgs = cliArgs[1:]
+	}
+
+	flag.CommandLine.Parse(cliArgs)
 
 	return &args
 }
 
 // Checks, if all necessarry cli arguments are passed.
 func CheckArguments(args *Arguments) (bool, string) {
+	command, ok := commands[args.Command]
+	if !ok {
+		return false, fmt.Sprintf("Unknown command \"%s\". See -h for more information.", args.Command)
+	} else if command.Offline {
+		return true, ""
+	}
+
 	if args.BaseUrl == "" {
 		return false, "No URL was given. See -h for more information"
 	}
@@ -67,13 +153,148 @@ func CheckArguments(args *Arguments) (bool, string) {
 	// Remove a leading / if it was provided
 	args.BaseUrl = strings.TrimSuffix(args.BaseUrl, "/")
 
-	if args.SeriesId == "" && args.Name == "" {
-		return false, "No SeriesID or Name was given. See -h for more information."
+	if args.Command == "download" && args.SeriesId == "" && args.SeasonId == "" && args.Name == "" && args.Playlist == "" {
+		return false, "No SeriesID, SeasonID, Name or Playlist was given. See -h for more information."
+	}
+
+	if _, err := GetSearchFilter(args); err != nil {
+		return false, err.Error()
+	}
+
+	if format := strings.ToLower(args.Format); format != "" && format != "text" && format != "csv" && format != "json" {
+		return false, "-format must be text, csv or json."
+	}
+
+	if args.Transcode && args.Container == "" {
+		return false, "-transcode requires a target -container."
+	}
+
+	if args.Command == "sync-watched" && args.WatchedFile == "" {
+		return false, "sync-watched requires a -watched file. See -h for more information."
+	}
+
+	if args.NextUp < 0 {
+		return false, "-next-up must not be negative."
+	}
+
+	if args.VersionFilter != "" {
+		filter := strings.ToLower(args.VersionFilter)
+		if match, _ := regexp.MatchString(`^(smallest|largest|\d+p)$`, filter); !match {
+			return false, "-version-filter must be smallest, largest or a resolution like 1080p."
+		}
+	}
+
+	if args.LimitSchedule != "" && args.LimitRate == "" {
+		return false, "-limit-schedule can only be used together with -limit-rate."
 	}
 
 	return true, ""
 }
 
+// Creates the download options which were requested by the given arguments.
+func GetDownloadOptions(args *Arguments) *jf_requests.DownloadOptions {
+	options := &jf_requests.DownloadOptions{
+		KeepFilenames: args.KeepFilenames,
+		VersionFilter: args.VersionFilter,
+		WriteNfo:      args.Nfo,
+		Images:        args.Images,
+		OutputDir:     args.Output,
+	}
+
+	options.SubtitleLanguages = splitList(args.Subs)
+
+	if args.Manifest {
+		options.Report = jf_requests.NewDownloadReport(args.Output)
+	}
+
+	if args.Verify {
+		options.Verify = true
+		checksums, err := jf_requests.ReadChecksumManifest(args.Output)
+		if err != nil {
+			color.Yellow("%s. Checksums will not be recorded.", err)
+		} else {
+			options.Checksums = checksums
+		}
+	}
+
+	if args.Transcode {
+		options.Transcode = &jf_requests.TranscodeOptions{
+			Container:           strings.TrimPrefix(args.Container, "."),
+			VideoCodec:          args.VideoCodec,
+			AudioCodec:          args.AudioCodec,
+			MaxBitrate:          args.MaxBitrate,
+			MaxHeight:           args.MaxHeight,
+			AudioStreamIndex:    args.AudioStream,
+			SubtitleStreamIndex: args.SubStream,
+		}
+	}
+
+	return options
+}
+
+// Splits a comma separated argument into its trimmed, non empty values.
+func splitList(value string) []string {
+	var values []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			values = append(values, entry)
+		}
+	}
+
+	return values
+}
+
+// Creates the filter for searches by name. Returns nil, if no filter was requested.
+func GetSearchFilter(args *Arguments) (*jf_requests.SearchFilter, error) {
+	if args.ItemType == "" && args.Year == "" && args.Genre == "" && args.Studio == "" && args.Library == "" {
+		return nil, nil
+	}
+
+	if args.Command == "download" && args.Name == "" {
+		return nil, fmt.Errorf("-type, -year, -genre, -studio and -library can only be used together with -name.")
+	}
+
+	types, err := jf_requests.ParseItemTypes(args.ItemType)
+	if err != nil {
+		return nil, fmt.Errorf("-type: %s", err)
+	}
+
+	years, err := jf_requests.ParseYears(args.Year)
+	if err != nil {
+		return nil, fmt.Errorf("-year: %s", err)
+	}
+
+	return &jf_requests.SearchFilter{
+		Types:   types,
+		Years:   years,
+		Genres:  splitList(args.Genre),
+		Studios: splitList(args.Studio),
+		Library: args.Library,
+	}, nil
+}
+
+// Creates the rate limiter for the given arguments. Returns nil, if no limit was requested.
+func GetRateLimiter(args *Arguments) (*jf_requests.RateLimiter, error) {
+	if args.LimitRate == "" {
+		return nil, nil
+	}
+
+	rate, err := jf_requests.ParseSize(args.LimitRate)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule *jf_requests.RateSchedule
+	if args.LimitSchedule != "" {
+		schedule, err = jf_requests.ParseRateSchedule(args.LimitSchedule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return jf_requests.NewRateLimiter(rate, schedule), nil
+}
+
 func GetUsername(args *Arguments) string {
 	if args.Username != "" {
 		return args.Username
@@ -116,39 +337,68 @@ func GetConfirmation() bool {
 	return response == "y"
 }
 
-func PrintItemSelection(itemsToSelect []jf_requests.Item) (*jf_requests.Item, error) {
-	fmt.Println("Found multiple Shows for the given Searchterm. Please Select the show you want to download:")
+// Lets the user select one or more of the given items, e.g. "1,3-5" or "!2".
+func PrintItemSelection(itemsToSelect []jf_requests.Item) ([]jf_requests.Item, error) {
+	fmt.Println("Found multiple Items for the given Searchterm. Please Select the items you want to download (e.g. 1,3-5 or !2):")
 
-	for idx, show := range itemsToSelect {
-		color.Cyan("  %d. %s", idx+1, show.Name)
+	for idx, item := range itemsToSelect {
+		details := item.Type
+		if item.ProductionYear != 0 {
+			details = fmt.Sprintf("%d, %s", item.ProductionYear, item.Type)
+		}
+		color.Cyan("  %d. %s (%s)", idx+1, item.Name, details)
 	}
 
-	choice, err := jf_requests.GetUserChoice(len(itemsToSelect))
+	choices, err := jf_requests.GetUserChoices(len(itemsToSelect), false)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Invalid selection: %s", err)
+	}
+
+	var selected []jf_requests.Item
+	for _, choice := range choices {
+		selected = append(selected, itemsToSelect[choice-1])
 	}
 
-	return &itemsToSelect[choice-1], nil
+	return selected, nil
 }
 
-func DownloadSeries(auth *jf_requests.AuthResponse, baseurl string, item *jf_requests.Item, seasonId string, keepFilenames bool) bool {
-	series, err := jf_requests.GetSeriesFromItem(auth.Token, baseurl, item)
+func DownloadSeries(ctx *DownloadContext, item *jf_requests.Item) bool {
+	series, err := jf_requests.GetSeriesFromItem(ctx.Auth, ctx.BaseUrl, item, ctx.Options.NeedsMetadata())
 	if err != nil {
 		color.Red("Failed to obtain Episode Information for given id: %s", err)
 		return false
 	}
 
+	series.ApplyFilter(ctx.EpisodeFilter)
+	if len(series.Seasons) == 0 {
+		if ctx.EpisodeFilter == nil || ctx.EpisodeFilter.IsEmpty() {
+			color.Yellow("\"%s\" does not contain any episodes.", item.Name)
+		} else {
+			color.Yellow("No episodes of \"%s\" match the given filters.", item.Name)
+		}
+
+		// When downloading many items, e.g. while mirroring with -unwatched, a series
+		// without matching episodes is skipped instead of failing the whole run.
+		return ctx.Confirmed
+	}
+
 	color.Green("Series: %s\n", item.Name)
 	var selected_seasons []jf_requests.Season
-	if seasonId != "" {
-		if selected_season, geterr := series.GetSeasonForId(seasonId); geterr == nil {
+	if ctx.SeasonId != "" {
+		if selected_season, geterr := series.GetSeasonForId(ctx.SeasonId); geterr == nil {
 			selected_seasons = []jf_requests.Season{*selected_season}
 		} else {
 			err = geterr
 		}
 
+	} else if ctx.Confirmed {
+		selected_seasons = series.Seasons
 	} else {
 		selected_seasons, err = series.PrintAndGetSelection()
+		// Scripts which pipe the season selection into the tool do not expect another prompt.
+		if err == nil && term.IsTerminal(int(os.Stdin.Fd())) {
+			selected_seasons, err = series.PrintAndGetEpisodeSelection(selected_seasons)
+		}
 	}
 
 	if err != nil {
@@ -156,74 +406,153 @@ func DownloadSeries(auth *jf_requests.AuthResponse, baseurl string, item *jf_req
 		return false
 	}
 
-	confirm := series.PrintAndGetConfirmation(selected_seasons)
+	confirm := ctx.Confirmed || series.PrintAndGetConfirmation(ctx.Options, selected_seasons)
 
 	if confirm {
-		for _, season := range selected_seasons {
-			season.Download(baseurl, auth.Token, keepFilenames)
+		if err := series.Download(ctx.BaseUrl, ctx.Auth.Token, ctx.Options, selected_seasons); err != nil {
+			color.Red("Failed to download \"%s\": %s", series.Name, err)
+			return false
 		}
 	}
 
 	return true
 }
 
-func DownloadMovie(auth *jf_requests.AuthResponse, baseurl string, item *jf_requests.Item, keepFilename bool) bool {
-	movie, err := jf_requests.GetMovieFromItem(auth, baseurl, item)
+func DownloadMovie(ctx *DownloadContext, item *jf_requests.Item) bool {
+	movie, err := jf_requests.GetMovieFromItem(ctx.Auth, ctx.BaseUrl, item)
 	if err != nil {
 		color.Red("Failed to obtain Movie for given id: %s", err)
 		return false
 	}
 
-	if movie.PrintAndGetConfirmation() {
-		movie.Download(keepFilename)
-	} else {
+	if err := movie.SelectVersion(ctx.Options.VersionFilter, !ctx.Confirmed); err != nil {
+		color.Red(err.Error())
+		return false
+	}
+
+	// Like non downloadable episodes, the movie is skipped without failing the run.
+	if ctx.Confirmed && !movie.CanDownload {
+		color.Yellow("Skipping non downloadable item: %s", movie.Name)
+		return true
+	}
+
+	if !ctx.Confirmed && !movie.PrintAndGetConfirmation(ctx.Options) {
+		return false
+	}
+
+	if err := movie.Download(ctx.BaseUrl, ctx.Auth.Token, ctx.Options); err != nil {
+		color.Red("Failed to download \"%s\": %s", movie.Name, err)
 		return false
 	}
 
 	return true
 }
 
+// Returns the playlist with the given id. If no playlist with this id exists, the playlists
+// are searched by name instead.
+func GetPlaylist(auth *jf_requests.AuthResponse, baseurl string, playlist string) (*jf_requests.Item, error) {
+	// Names are searched for, only ids are looked up directly.
+	if jf_requests.IsItemId(playlist) {
+		if item, err := jf_requests.GetItemForId(auth, baseurl, playlist); err == nil && item.Type == "Playlist" {
+			return item, nil
+		}
+	}
+
+	items, err := jf_requests.GetPlaylistsForText(auth, baseurl, playlist)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to search for playlists: %s", err)
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("Did not found a playlist for \"%s\" on the Server.", playlist)
+	} else if len(items) == 1 {
+		return &items[0], nil
+	}
+
+	selected, err := PrintItemSelection(items)
+	if err != nil {
+		return nil, err
+	} else if len(selected) != 1 {
+		return nil, fmt.Errorf("Only select a single playlist")
+	}
+
+	return &selected[0], nil
+}
+
+// Creates the download context for the given arguments.
+func NewDownloadContext(args *Arguments, auth *jf_requests.AuthResponse) *DownloadContext {
+	return &DownloadContext{
+		Auth:     auth,
+		BaseUrl:  args.BaseUrl,
+		SeasonId: args.SeasonId,
+		Options:  GetDownloadOptions(args),
+		EpisodeFilter: &jf_requests.EpisodeFilter{
+			Unwatched:  args.Unwatched,
+			Favorites:  args.Favorites,
+			InProgress: args.InProgress,
+			NextUp:     args.NextUp,
+		},
+	}
+}
+
 func Download(args *Arguments, auth *jf_requests.AuthResponse) bool {
-	if args.SeriesId != "" {
+	ctx := NewDownloadContext(args, auth)
+
+	if args.Playlist != "" {
+		item, err := GetPlaylist(auth, args.BaseUrl, args.Playlist)
+		if err != nil {
+			color.Red(err.Error())
+			return false
+		}
+
+		return DownloadPlaylist(ctx, item)
+
+	} else if args.SeriesId != "" {
 		item, err := jf_requests.GetItemForId(auth, args.BaseUrl, args.SeriesId)
 		if err != nil {
 			color.Red("Failed to obtain items for given id: %s", err)
 			return false
 		}
 
-		if item.Type == "Series" {
-			return DownloadSeries(auth, args.BaseUrl, item, args.SeasonId, args.KeepFilenames)
-		} else {
-			return DownloadMovie(auth, args.BaseUrl, item, args.KeepFilenames)
+		return DownloadItem(ctx, item)
+
+	} else if args.SeasonId != "" && args.Name == "" {
+		// Without a series id, the season is resolved on its own.
+		item, err := jf_requests.GetItemForId(auth, args.BaseUrl, args.SeasonId)
+		if err != nil {
+			color.Red("Failed to obtain items for given id: %s", err)
+			return false
 		}
 
+		return DownloadItem(ctx, item)
+
 	} else if args.Name != "" {
-		items, err := jf_requests.GetItemsForText(auth, args.BaseUrl, args.Name)
+		filter, _ := GetSearchFilter(args)
+		items, err := jf_requests.GetItemsForText(auth, args.BaseUrl, args.Name, filter)
 		if err != nil {
 			color.Red("Failed to obtain Episode Information for given id: %s", err)
 			return false
 		}
 
-		var item *jf_requests.Item
 		if len(items) == 0 {
 			color.Yellow("Did not found anything for the given Searchterm on the Server.")
 			return false
 		} else if len(items) == 1 {
-			item = &items[0]
-		} else {
-			item, err = PrintItemSelection(items)
-			if err != nil {
-				color.Red(err.Error())
-				return false
-			}
+			return DownloadItem(ctx, &items[0])
 		}
 
-		if item.Type == "Series" {
-			return DownloadSeries(auth, args.BaseUrl, item, args.SeasonId, args.KeepFilenames)
-		} else {
-			return DownloadMovie(auth, args.BaseUrl, item, args.KeepFilenames)
+		selected, err := PrintItemSelection(items)
+		if err != nil {
+			color.Red(err.Error())
+			return false
 		}
 
+		if len(selected) == 1 {
+			return DownloadItem(ctx, &selected[0])
+		}
+
+		return downloadItems(ctx, &jf_requests.Item{Name: args.Name}, selected)
+
 	}
 
 	return false
@@ -262,6 +591,35 @@ func main() {
 		os.Exit(1)
 	}
 
+	jf_requests.SetRetryPolicy(jf_requests.RetryPolicy{
+		MaxAttempts: args.Retries + 1,
+		BaseDelay:   args.RetryDelay,
+		MaxDelay:    jf_requests.DefaultRetryPolicy.MaxDelay,
+	})
+
+	limiter, err := GetRateLimiter(args)
+	if err != nil {
+		color.Red("Wrong Arguments: %s\n", err)
+		os.Exit(1)
+	}
+	jf_requests.SetRateLimiter(limiter)
+
+	if args.MinFree != "" {
+		minFree, err := jf_requests.ParseSize(args.MinFree)
+		if err != nil {
+			color.Red("Wrong Arguments: -min-free: %s\n", err)
+			os.Exit(1)
+		}
+		jf_requests.SetMinFreeSpace(minFree)
+	}
+
+	if commands[args.Command].Offline {
+		if !commands[args.Command].Run(args, nil) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	username := GetUsername(args)
 	password := GetPassword(args)
 
@@ -271,7 +629,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	result := Download(args, creds)
+	result := commands[args.Command].Run(args, creds)
 	if !result {
 		os.Exit(1)
 	}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"jf_requests/jf_requests"
+	"path/filepath"
+
+	"github.com/fatih/color"
+)
+
+// A library together with the series and movies which should be mirrored from it.
+type mirroredLibrary struct {
+	Library jf_requests.Item
+	Items   []jf_requests.Item
+}
+
+// Returns the libraries given by -library, or all libraries if none was given.
+func getMirroredLibraries(args *Arguments, auth *jf_requests.AuthResponse) ([]jf_requests.Item, error) {
+	names := splitList(args.Library)
+	if len(names) == 0 {
+		return jf_requests.GetRootItems(auth, args.BaseUrl)
+	}
+
+	var libraries []jf_requests.Item
+	for _, name := range names {
+		library, err := jf_requests.GetLibraryForName(auth, args.BaseUrl, name)
+		if err != nil {
+			return nil, err
+		}
+		libraries = append(libraries, *library)
+	}
+
+	return libraries, nil
+}
+
+// Returns the directory of a mirrored item within its library, e.g. "Movies/Heat (1995)".
+func getMirrorDirectory(library *jf_requests.Item, item *jf_requests.Item) string {
+	name := item.Name
+	if item.Type == "Movie" && item.ProductionYear != 0 {
+		name = fmt.Sprintf("%s (%d)", item.Name, item.ProductionYear)
+	}
+
+	return filepath.Join(jf_requests.SanitizeFilename(library.Name), jf_requests.SanitizeFilename(name))
+}
+
+// Mirrors all series and movies of the selected libraries into the output directory. Files
+// which already exist are skipped, so an interrupted mirror can simply be started again.
+func Mirror(args *Arguments, auth *jf_requests.AuthResponse) bool {
+	ctx := NewDownloadContext(args, auth)
+	ctx.Confirmed = true
+	ctx.Options.SkipExisting = true
+	ctx.Options.Stats = &jf_requests.DownloadStats{}
+	ctx.Options.InSeriesDirectory = true
+
+	filter, _ := GetSearchFilter(args)
+	if filter == nil {
+		filter = &jf_requests.SearchFilter{}
+	}
+	if len(filter.Types) == 0 {
+		filter.Types = []string{"Series", "Movie"}
+	}
+
+	libraries, err := getMirroredLibraries(args, auth)
+	if err != nil {
+		color.Red("Failed to obtain the libraries: %s", err)
+		return false
+	}
+
+	// Items may show up in multiple libraries, e.g. in a collection library as well.
+	seen := make(map[string]bool)
+	var mirrored []mirroredLibrary
+	total := 0
+	for idx := range libraries {
+		items, err := jf_requests.GetItemsOfLibrary(auth, args.BaseUrl, &libraries[idx], filter)
+		if err != nil {
+			color.Red(err.Error())
+			return false
+		}
+
+		var unique []jf_requests.Item
+		for _, item := range items {
+			if !seen[item.Id] {
+				seen[item.Id] = true
+				unique = append(unique, item)
+			}
+		}
+
+		if len(unique) > 0 {
+			mirrored = append(mirrored, mirroredLibrary{Library: libraries[idx], Items: unique})
+			total += len(unique)
+		}
+	}
+
+	if total == 0 {
+		color.Yellow("There is nothing to mirror in the selected libraries.")
+		return false
+	}
+
+	outputDir := ctx.Options.OutputDir
+	if outputDir == "" {
+		outputDir = "."
+	}
+
+	fmt.Printf("The following libraries will be mirrored into \"%s\":\n", outputDir)
+	for _, entry := range mirrored {
+		color.Cyan("  %s: %d items", entry.Library.Name, len(entry.Items))
+	}
+
+	if !GetConfirmation() {
+		return false
+	}
+
+	success := true
+	current := 0
+mirror:
+	for _, entry := range mirrored {
+		for idx := range entry.Items {
+			if err := jf_requests.CheckMinFreeSpace(ctx.Options.OutputDir); err != nil {
+				color.Red("Stopping the mirror: %s", err)
+				success = false
+				break mirror
+			}
+
+			item := &entry.Items[idx]
+			current++
+			color.Green("[%d/%d] %s", current, total, item.Name)
+
+			itemCtx := *ctx
+			options := *ctx.Options
+			options.OutputDir = ctx.Options.GetOutputPath(getMirrorDirectory(&entry.Library, item))
+			itemCtx.Options = &options
+
+			if !DownloadItem(&itemCtx, item) {
+				success = false
+			}
+		}
+	}
+
+	fmt.Printf("Mirrored %d items: %s\n", total, ctx.Options.Stats)
+	return success && ctx.Options.Stats.Failed == 0
+}